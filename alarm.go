@@ -1,7 +1,6 @@
 package ical2
 
 import (
-	"fmt"
 	"github.com/rickb777/ical2/ics"
 	"github.com/rickb777/ical2/value"
 )
@@ -18,6 +17,9 @@ type VAudioAlarm struct {
 	Duration value.DurationValue
 	Repeat   value.IntegerValue
 	Attach   value.Attachable // optional
+
+	// Extensions holds any additional non-standard or unsupported properties.
+	Extensions []Extension
 }
 
 // VDisplayAlarm captures a calendar event
@@ -26,6 +28,9 @@ type VDisplayAlarm struct {
 	Trigger     value.Trigger   // required
 	Duration    value.DurationValue
 	Repeat      value.IntegerValue
+
+	// Extensions holds any additional non-standard or unsupported properties.
+	Extensions []Extension
 }
 
 // VEmailAlarm captures a calendar event
@@ -37,29 +42,58 @@ type VEmailAlarm struct {
 	Duration    value.DurationValue
 	Repeat      value.IntegerValue
 	Attach      []value.Attachable // optional
+
+	// Extensions holds any additional non-standard or unsupported properties.
+	Extensions []Extension
 }
 
 // IsAlarm marks this type.
 func (e *VAudioAlarm) IsAlarm() {}
 
-// EncodeIcal serialises the event to the buffer in iCalendar ics format
-func (e *VAudioAlarm) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+// Validate checks the alarm, returning every problem found.
+func (e *VAudioAlarm) Validate() Problems {
+	return e.validate("", 0, value.TextValue{})
+}
 
-	if !ics.IsDefined(e.Trigger) {
-		return fmt.Errorf("Trigger is required")
-	}
+func (e *VAudioAlarm) validate(parent string, index int, method value.TextValue) Problems {
+	v := &validation{parent: parent, name: "VALARM", index: index}
+	v.schema("VALARM/AUDIO", newCounter().
+		add("ACTION", value.Text("AUDIO")).
+		add("TRIGGER", e.Trigger).
+		add("DURATION", &e.Duration).
+		add("REPEAT", &e.Repeat).
+		add("ATTACH", e.Attach))
+	v.values("TRIGGER", e.Trigger)
+	v.values("DURATION", &e.Duration)
+	v.extensions(e.Extensions)
+	return v.problems
+}
+
+// Extend adds an extension property to the alarm.
+// The alarm is modified and is returned.
+func (e *VAudioAlarm) Extend(key string, value ics.Valuer) *VAudioAlarm {
+	e.Extensions = append(e.Extensions, Extension{key, value})
+	return e
+}
 
-	if ics.IsDefined(e.Duration) != ics.IsDefined(e.Repeat) {
-		return fmt.Errorf("Duration and Repeat must both be present or absent")
+// EncodeIcal serialises the event to the buffer in iCalendar ics format
+func (e *VAudioAlarm) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+	if err := e.validate("", 0, method).Err(); err != nil {
+		return err
 	}
+	return e.encodeIcal(b, method)
+}
 
+// encodeIcal writes the alarm without validating it first.
+func (e *VAudioAlarm) encodeIcal(b *ics.Buffer, method value.TextValue) error {
 	b.WriteLine("BEGIN:VALARM")
 	b.WriteLine("ACTION:AUDIO")
 
 	b.WriteValuerLine(true, "TRIGGER", e.Trigger)
-	b.WriteValuerLine(ics.IsDefined(e.Duration), "DURATION", e.Duration)
-	b.WriteValuerLine(ics.IsDefined(e.Repeat), "REPEAT", e.Repeat)
+	b.WriteValuerLine(ics.IsDefined(e.Duration), "DURATION", &e.Duration)
+	b.WriteValuerLine(ics.IsDefined(e.Repeat), "REPEAT", &e.Repeat)
 	b.WriteValuerLine(ics.IsDefined(e.Attach), "ATTACH", e.Attach)
+	writeExtensions(b, e.Extensions)
 
 	b.WriteLine("END:VALARM")
 
@@ -69,28 +103,50 @@ func (e *VAudioAlarm) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
 // IsAlarm marks this type.
 func (e *VDisplayAlarm) IsAlarm() {}
 
-// EncodeIcal serialises the event to the buffer in iCalendar ics format
-func (e *VDisplayAlarm) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+// Validate checks the alarm, returning every problem found.
+func (e *VDisplayAlarm) Validate() Problems {
+	return e.validate("", 0, value.TextValue{})
+}
 
-	if !ics.IsDefined(e.Description) {
-		return fmt.Errorf("Description is required")
-	}
+func (e *VDisplayAlarm) validate(parent string, index int, method value.TextValue) Problems {
+	v := &validation{parent: parent, name: "VALARM", index: index}
+	v.schema("VALARM/DISPLAY", newCounter().
+		add("ACTION", value.Text("DISPLAY")).
+		add("DESCRIPTION", &e.Description).
+		add("TRIGGER", e.Trigger).
+		add("DURATION", &e.Duration).
+		add("REPEAT", &e.Repeat))
+	v.values("TRIGGER", e.Trigger)
+	v.values("DURATION", &e.Duration)
+	v.extensions(e.Extensions)
+	return v.problems
+}
 
-	if !ics.IsDefined(e.Trigger) {
-		return fmt.Errorf("Trigger is required")
-	}
+// Extend adds an extension property to the alarm.
+// The alarm is modified and is returned.
+func (e *VDisplayAlarm) Extend(key string, value ics.Valuer) *VDisplayAlarm {
+	e.Extensions = append(e.Extensions, Extension{key, value})
+	return e
+}
 
-	if ics.IsDefined(e.Duration) != ics.IsDefined(e.Repeat) {
-		return fmt.Errorf("Duration and Repeat must both be present or absent")
+// EncodeIcal serialises the event to the buffer in iCalendar ics format
+func (e *VDisplayAlarm) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+	if err := e.validate("", 0, method).Err(); err != nil {
+		return err
 	}
+	return e.encodeIcal(b, method)
+}
 
+// encodeIcal writes the alarm without validating it first.
+func (e *VDisplayAlarm) encodeIcal(b *ics.Buffer, method value.TextValue) error {
 	b.WriteLine("BEGIN:VALARM")
 	b.WriteLine("ACTION:DISPLAY")
 
-	b.WriteValuerLine(true, "DESCRIPTION", e.Description)
+	b.WriteValuerLine(true, "DESCRIPTION", &e.Description)
 	b.WriteValuerLine(true, "TRIGGER", e.Trigger)
-	b.WriteValuerLine(ics.IsDefined(e.Duration), "DURATION", e.Duration)
-	b.WriteValuerLine(ics.IsDefined(e.Repeat), "REPEAT", e.Repeat)
+	b.WriteValuerLine(ics.IsDefined(e.Duration), "DURATION", &e.Duration)
+	b.WriteValuerLine(ics.IsDefined(e.Repeat), "REPEAT", &e.Repeat)
+	writeExtensions(b, e.Extensions)
 
 	b.WriteLine("END:VALARM")
 
@@ -100,43 +156,61 @@ func (e *VDisplayAlarm) EncodeIcal(b *ics.Buffer, method value.TextValue) error
 // IsAlarm marks this type.
 func (e *VEmailAlarm) IsAlarm() {}
 
-// EncodeIcal serialises the event to the buffer in iCalendar ics format
-func (e *VEmailAlarm) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
-
-	if !ics.IsDefined(e.Description) {
-		return fmt.Errorf("Description is required")
-	}
-
-	if !ics.IsDefined(e.Trigger) {
-		return fmt.Errorf("Trigger is required")
-	}
+// Validate checks the alarm, returning every problem found.
+func (e *VEmailAlarm) Validate() Problems {
+	return e.validate("", 0, value.TextValue{})
+}
 
-	if !ics.IsDefined(e.Summary) {
-		return fmt.Errorf("Summary is required")
-	}
+func (e *VEmailAlarm) validate(parent string, index int, method value.TextValue) Problems {
+	v := &validation{parent: parent, name: "VALARM", index: index}
+	c := newCounter().
+		add("ACTION", value.Text("EMAIL")).
+		add("DESCRIPTION", &e.Description).
+		add("TRIGGER", e.Trigger).
+		add("SUMMARY", &e.Summary).
+		add("DURATION", &e.Duration).
+		add("REPEAT", &e.Repeat)
+	countAll(c, "ATTENDEE", e.Attendee)
+	countEach(c, "ATTACH", e.Attach)
+	v.schema("VALARM/EMAIL", c)
+	v.values("TRIGGER", e.Trigger)
+	v.values("DURATION", &e.Duration)
+	v.extensions(e.Extensions)
+	return v.problems
+}
 
-	if len(e.Attendee) < 1 {
-		return fmt.Errorf("At least one attendee is required")
-	}
+// Extend adds an extension property to the alarm.
+// The alarm is modified and is returned.
+func (e *VEmailAlarm) Extend(key string, value ics.Valuer) *VEmailAlarm {
+	e.Extensions = append(e.Extensions, Extension{key, value})
+	return e
+}
 
-	if ics.IsDefined(e.Duration) != ics.IsDefined(e.Repeat) {
-		return fmt.Errorf("Duration and Repeat must both be present or absent")
+// EncodeIcal serialises the event to the buffer in iCalendar ics format
+func (e *VEmailAlarm) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+	if err := e.validate("", 0, method).Err(); err != nil {
+		return err
 	}
+	return e.encodeIcal(b, method)
+}
 
+// encodeIcal writes the alarm without validating it first.
+func (e *VEmailAlarm) encodeIcal(b *ics.Buffer, method value.TextValue) error {
 	b.WriteLine("BEGIN:VALARM")
 	b.WriteLine("ACTION:EMAIL")
 
-	b.WriteValuerLine(true, "DESCRIPTION", e.Description)
+	b.WriteValuerLine(true, "DESCRIPTION", &e.Description)
 	b.WriteValuerLine(true, "TRIGGER", e.Trigger)
-	b.WriteValuerLine(true, "SUMMARY", e.Summary)
-	for _, attendee := range e.Attendee {
-		b.WriteValuerLine(true, "ATTENDEE", attendee)
+	b.WriteValuerLine(true, "SUMMARY", &e.Summary)
+	for i := range e.Attendee {
+		b.WriteValuerLine(true, "ATTENDEE", &e.Attendee[i])
 	}
-	b.WriteValuerLine(ics.IsDefined(e.Duration), "DURATION", e.Duration)
-	b.WriteValuerLine(ics.IsDefined(e.Repeat), "REPEAT", e.Repeat)
+	b.WriteValuerLine(ics.IsDefined(e.Duration), "DURATION", &e.Duration)
+	b.WriteValuerLine(ics.IsDefined(e.Repeat), "REPEAT", &e.Repeat)
 	for _, attach := range e.Attach {
 		b.WriteValuerLine(ics.IsDefined(attach), "ATTACH", attach)
 	}
+	writeExtensions(b, e.Extensions)
 
 	b.WriteLine("END:VALARM")
 
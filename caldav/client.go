@@ -0,0 +1,369 @@
+// Package caldav provides a client for CalDAV servers, allowing calendars to be
+// pulled from and pushed to them. It also provides change tracking for servers that
+// answer sync-collection requests.
+//
+// See
+// https://tools.ietf.org/html/rfc4791
+// https://tools.ietf.org/html/rfc4918
+// https://tools.ietf.org/html/rfc5397
+// https://tools.ietf.org/html/rfc6578
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/rickb777/ical2"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to a CalDAV server. Authentication is not handled here; supply an
+// http.Client whose Transport adds the necessary credentials.
+type Client struct {
+	http *http.Client
+	base *url.URL
+}
+
+// Calendar describes a calendar collection found on the server.
+type Calendar struct {
+	// Path is the absolute path of the collection on the server.
+	Path string
+	// Name is the display name of the calendar.
+	Name string
+	// Description is the calendar description, if any.
+	Description string
+	// SupportedComponents lists the component names the calendar accepts,
+	// e.g. "VEVENT". It is empty if the server did not say.
+	SupportedComponents []string
+	// CTag changes whenever the content of the calendar changes. Not all
+	// servers provide it.
+	CTag string
+}
+
+// Object is a calendar object resource, i.e. one iCalendar file held on the server.
+type Object struct {
+	// Path is the absolute path of the object on the server.
+	Path string
+	// ETag is the entity tag of the object, needed for conditional updates.
+	ETag string
+	// Data is the calendar content.
+	Data *ical2.VCalendar
+}
+
+// StatusError reports an unexpected HTTP response status.
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+
+	// set when the server reported the DAV:valid-sync-token precondition
+	validSyncTokenFailed bool
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// IsPreconditionFailed tests whether an error was caused by an ETag mismatch, which
+// happens when an object has been changed on the server by someone else. Wrapped
+// errors are unwrapped to find the StatusError.
+func IsPreconditionFailed(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.StatusCode == http.StatusPreconditionFailed
+}
+
+// NewClient returns a client for the CalDAV server at the endpoint URL. If httpClient
+// is nil, http.DefaultClient is used.
+func NewClient(httpClient *http.Client, endpoint string) (*Client, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", endpoint)
+	}
+
+	return &Client{http: httpClient, base: base}, nil
+}
+
+// FindCurrentUserPrincipal returns the path of the principal of the authenticated
+// user (RFC 5397).
+func (c *Client) FindCurrentUserPrincipal(ctx context.Context) (string, error) {
+	ms, err := c.propfind(ctx, c.base.Path, "0", propfindPrincipal)
+	if err != nil {
+		return "", err
+	}
+
+	for _, r := range ms.Responses {
+		if p := r.props(); p.CurrentUserPrincipal != nil {
+			return p.CurrentUserPrincipal.Href, nil
+		}
+	}
+
+	return "", fmt.Errorf("no current-user-principal found")
+}
+
+// FindCalendarHomeSet returns the path of the collection holding a principal's
+// calendars. If principal is blank, the current user principal is discovered first.
+func (c *Client) FindCalendarHomeSet(ctx context.Context, principal string) (string, error) {
+	if principal == "" {
+		var err error
+		principal, err = c.FindCurrentUserPrincipal(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	ms, err := c.propfind(ctx, principal, "0", propfindHomeSet)
+	if err != nil {
+		return "", err
+	}
+
+	for _, r := range ms.Responses {
+		if p := r.props(); p.CalendarHomeSet != nil {
+			return p.CalendarHomeSet.Href, nil
+		}
+	}
+
+	return "", fmt.Errorf("no calendar-home-set found for %s", principal)
+}
+
+// FindCalendars lists the calendar collections within a calendar home set.
+func (c *Client) FindCalendars(ctx context.Context, homeSet string) ([]Calendar, error) {
+	ms, err := c.propfind(ctx, homeSet, "1", propfindCalendars)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Calendar
+	for _, r := range ms.Responses {
+		p := r.props()
+		if p.ResourceType == nil || p.ResourceType.Calendar == nil {
+			continue
+		}
+
+		cal := Calendar{
+			Path:        r.Href,
+			Name:        p.DisplayName,
+			Description: p.CalendarDescription,
+			CTag:        p.GetCTag,
+		}
+		if p.SupportedComponents != nil {
+			for _, comp := range p.SupportedComponents.Comp {
+				cal.SupportedComponents = append(cal.SupportedComponents, comp.Name)
+			}
+		}
+		list = append(list, cal)
+	}
+
+	return list, nil
+}
+
+// QueryEvents fetches the events in a calendar that overlap the time range from
+// start (inclusive) to end (exclusive), using a calendar-query REPORT.
+func (c *Client) QueryEvents(ctx context.Context, calendarPath string, start, end time.Time) ([]Object, error) {
+	return c.Query(ctx, calendarPath, "VEVENT", start, end)
+}
+
+// Query fetches the objects in a calendar that contain a component of the given
+// name (e.g. "VEVENT") overlapping the time range from start (inclusive) to end
+// (exclusive), using a calendar-query REPORT. The name must be an iana-token or
+// x-name, i.e. letters, digits and hyphens only.
+// https://tools.ietf.org/html/rfc4791#section-7.8
+func (c *Client) Query(ctx context.Context, calendarPath, component string, start, end time.Time) ([]Object, error) {
+	if !isToken(component) {
+		return nil, fmt.Errorf("%q is not a valid component name", component)
+	}
+
+	const layout = "20060102T150405Z"
+	body := fmt.Sprintf(calendarQuery, component, start.UTC().Format(layout), end.UTC().Format(layout))
+
+	ms, err := c.report(ctx, calendarPath, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return objects(ms)
+}
+
+// isToken tests whether a name is an iana-token or x-name, so that it is safe to
+// insert into XML.
+func isToken(name string) bool {
+	for _, r := range name {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// GetObject fetches a single calendar object.
+func (c *Client) GetObject(ctx context.Context, path string) (*Object, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	cal, err := ical2.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &Object{Path: req.URL.Path, ETag: resp.Header.Get("ETag"), Data: cal}, nil
+}
+
+// PutObject creates or updates a calendar object and returns its new ETag, if the
+// server provided one.
+//
+// If etag is blank, the object must not already exist (If-None-Match: *).
+// Otherwise, the object is only updated if it still has that ETag (If-Match).
+// Use IsPreconditionFailed to detect when this condition was not met.
+func (c *Client) PutObject(ctx context.Context, path string, cal *ical2.VCalendar, etag string) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := cal.Encode(buf); err != nil {
+		return "", err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPut, path, buf)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	if etag == "" {
+		req.Header.Set("If-None-Match", "*")
+	} else {
+		req.Header.Set("If-Match", etag)
+	}
+
+	resp, err := c.do(req, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	return resp.Header.Get("ETag"), nil
+}
+
+// DeleteObject deletes a calendar object. If etag is not blank, the object is only
+// deleted if it still has that ETag (If-Match).
+func (c *Client) DeleteObject(ctx context.Context, path, etag string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, path, nil)
+	if err != nil {
+		return err
+	}
+
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	resp, err := c.do(req, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func objects(ms *multistatus) ([]Object, error) {
+	var list []Object
+	for _, r := range ms.Responses {
+		p := r.props()
+		if p.CalendarData == "" {
+			continue
+		}
+
+		cal, err := ical2.Decode(strings.NewReader(p.CalendarData))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Href, err)
+		}
+
+		list = append(list, Object{Path: r.Href, ETag: p.GetETag, Data: cal})
+	}
+	return list, nil
+}
+
+func (c *Client) propfind(ctx context.Context, path, depth, body string) (*multistatus, error) {
+	req, err := c.newRequest(ctx, "PROPFIND", path, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Depth", depth)
+	return c.multistatus(req)
+}
+
+func (c *Client) report(ctx context.Context, path, body string) (*multistatus, error) {
+	req, err := c.newRequest(ctx, "REPORT", path, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Depth", "1")
+	return c.multistatus(req)
+}
+
+func (c *Client) multistatus(req *http.Request) (*multistatus, error) {
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := c.do(req, http.StatusMultiStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ms := &multistatus{}
+	if err := xml.NewDecoder(resp.Body).Decode(ms); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err)
+	}
+	return ms, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.NewRequestWithContext(ctx, method, c.base.ResolveReference(ref).String(), body)
+}
+
+// do sends the request and checks that the response has one of the expected statuses.
+func (c *Client) do(req *http.Request, expected ...int) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+
+	se := &StatusError{Method: req.Method, Path: req.URL.Path, StatusCode: resp.StatusCode}
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusConflict {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		se.validSyncTokenFailed = strings.Contains(string(body), "valid-sync-token")
+	}
+	resp.Body.Close()
+	return nil, se
+}
@@ -0,0 +1,275 @@
+package caldav
+
+import (
+	"context"
+	"fmt"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/value"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const event1 = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+BEGIN:VEVENT
+DTSTART:20240102T100000Z
+DTEND:20240102T110000Z
+DTSTAMP:20240101T000000Z
+UID:event-1
+SUMMARY:First
+END:VEVENT
+END:VCALENDAR
+`
+
+// fakeServer is a minimal CalDAV stand-in. It supports just enough of the protocol
+// for the client tests.
+type fakeServer struct {
+	mu      sync.Mutex
+	objects map[string]*fakeObject
+	version int
+	lastReq string
+}
+
+type fakeObject struct {
+	etag string
+	data string
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{objects: map[string]*fakeObject{
+		"/cal/home/work/event-1.ics": {etag: `"1"`, data: event1},
+	}, version: 1}
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	s.lastReq = string(body)
+
+	switch r.Method + " " + r.URL.Path {
+	case "PROPFIND /dav/":
+		multiStatus(w, `<d:response><d:href>/dav/</d:href><d:propstat><d:prop>
+<d:current-user-principal><d:href>/principals/joe/</d:href></d:current-user-principal>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+
+	case "PROPFIND /principals/joe/":
+		multiStatus(w, `<d:response><d:href>/principals/joe/</d:href><d:propstat><d:prop>
+<c:calendar-home-set><d:href>/cal/home/</d:href></c:calendar-home-set>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+
+	case "PROPFIND /cal/home/":
+		multiStatus(w, `<d:response><d:href>/cal/home/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/></d:resourcetype>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/cal/home/work/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>
+<d:displayname>Work</d:displayname>
+<c:supported-calendar-component-set><c:comp name="VEVENT"/><c:comp name="VTODO"/></c:supported-calendar-component-set>
+<cs:getctag>ctag-1</cs:getctag>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>
+<d:propstat><d:prop><c:calendar-description/></d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response>`)
+
+	case "REPORT /cal/home/work/":
+		var sb strings.Builder
+		for path, obj := range s.objects {
+			fmt.Fprintf(&sb, `<d:response><d:href>%s</d:href><d:propstat><d:prop>
+<d:getetag>%s</d:getetag><c:calendar-data>%s</c:calendar-data>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, path, obj.etag, obj.data)
+		}
+		multiStatus(w, sb.String())
+
+	default:
+		s.serveObject(w, r, string(body))
+	}
+}
+
+func (s *fakeServer) serveObject(w http.ResponseWriter, r *http.Request, body string) {
+	obj, exists := s.objects[r.URL.Path]
+
+	if m := r.Header.Get("If-Match"); m != "" && (!exists || obj.etag != m) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", obj.etag)
+		io.WriteString(w, obj.data)
+
+	case http.MethodPut:
+		s.version++
+		s.objects[r.URL.Path] = &fakeObject{etag: fmt.Sprintf(`"%d"`, s.version), data: body}
+		w.Header().Set("ETag", s.objects[r.URL.Path].etag)
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+
+	case http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func multiStatus(w http.ResponseWriter, responses string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">%s</d:multistatus>`, responses)
+}
+
+func TestDiscovery(t *testing.T) {
+	srv := httptest.NewServer(newFakeServer())
+	defer srv.Close()
+
+	c, err := NewClient(srv.Client(), srv.URL+"/dav/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	home, err := c.FindCalendarHomeSet(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home != "/cal/home/" {
+		t.Errorf("got %q", home)
+	}
+
+	cals, err := c.FindCalendars(ctx, home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cals) != 1 {
+		t.Fatalf("got %+v", cals)
+	}
+
+	cal := cals[0]
+	if cal.Path != "/cal/home/work/" || cal.Name != "Work" || cal.CTag != "ctag-1" ||
+		strings.Join(cal.SupportedComponents, ",") != "VEVENT,VTODO" {
+		t.Errorf("got %+v", cal)
+	}
+}
+
+func TestQueryEvents(t *testing.T) {
+	fs := newFakeServer()
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	c, _ := NewClient(srv.Client(), srv.URL)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	objs, err := c.QueryEvents(context.Background(), "/cal/home/work/", start, start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(fs.lastReq, `<c:time-range start="20240101T000000Z" end="20240201T000000Z"/>`) {
+		t.Errorf("got %s", fs.lastReq)
+	}
+
+	if len(objs) != 1 {
+		t.Fatalf("got %+v", objs)
+	}
+
+	obj := objs[0]
+	if obj.Path != "/cal/home/work/event-1.ics" || obj.ETag != `"1"` {
+		t.Errorf("got %+v", obj)
+	}
+
+	e := obj.Data.VComponent[0].(*ical2.VEvent)
+	if e.UID.Value != "event-1" || e.Summary.Value != "First" {
+		t.Errorf("got %+v", e)
+	}
+
+	fs.lastReq = ""
+	if _, err := c.Query(context.Background(), "/cal/home/work/", `VEVENT"/><x`, start, start); err == nil || fs.lastReq != "" {
+		t.Errorf("got %v %s", err, fs.lastReq)
+	}
+}
+
+func TestCreateUpdateDelete(t *testing.T) {
+	srv := httptest.NewServer(newFakeServer())
+	defer srv.Close()
+
+	c, _ := NewClient(srv.Client(), srv.URL)
+	ctx := context.Background()
+	const path = "/cal/home/work/event-2.ics"
+
+	dt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := &ical2.VEvent{
+		UID:     value.Text("event-2"),
+		DTStamp: value.TStamp(dt),
+		Start:   value.DateTime(dt.Add(time.Hour)),
+		Summary: value.Text("Second"),
+	}
+	cal := ical2.NewVCalendar("-//Test//EN").With(event)
+
+	etag, err := c.PutObject(ctx, path, cal, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// creating it again must fail because it already exists
+	_, err = c.PutObject(ctx, path, cal, "")
+	if !IsPreconditionFailed(err) {
+		t.Errorf("got %v", err)
+	}
+
+	event.Summary = value.Text("Second, revised")
+	etag2, err := c.PutObject(ctx, path, cal, etag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// updating with a stale etag must fail
+	_, err = c.PutObject(ctx, path, cal, etag)
+	if !IsPreconditionFailed(err) || !IsPreconditionFailed(fmt.Errorf("put: %w", err)) {
+		t.Errorf("got %v", err)
+	}
+
+	obj, err := c.GetObject(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.ETag != etag2 || obj.Data.VComponent[0].(*ical2.VEvent).Summary.Value != "Second, revised" {
+		t.Errorf("got %+v", obj)
+	}
+
+	if err = c.DeleteObject(ctx, path, etag); !IsPreconditionFailed(err) {
+		t.Errorf("got %v", err)
+	}
+
+	if err = c.DeleteObject(ctx, path, etag2); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.GetObject(ctx, path)
+	if se, ok := err.(*StatusError); !ok || se.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package caldav
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// This file implements WebDAV collection synchronisation.
+// https://tools.ietf.org/html/rfc6578
+
+// ChangeKind describes how a resource changed.
+type ChangeKind int
+
+const (
+	// Added means the resource was created.
+	Added ChangeKind = iota + 1
+	// Modified means the resource was updated.
+	Modified
+	// Deleted means the resource was removed.
+	Deleted
+)
+
+// String returns "added", "modified" or "deleted".
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Change records that the resource for a UID was added, modified or deleted.
+type Change struct {
+	UID  string
+	Kind ChangeKind
+}
+
+// ErrInvalidSyncToken is returned when a sync token was not issued by the change log,
+// or has expired because the changes since it was issued are no longer held.
+// The client must then do a full resynchronisation.
+var ErrInvalidSyncToken = errors.New("invalid or expired sync token")
+
+// tokenPrefix starts every sync token. RFC 6578 requires tokens to be URIs.
+const tokenPrefix = "urn:x-ical2:sync:"
+
+// ChangeLog keeps a bounded history of the changes to the resources in one collection,
+// identified by UID. It issues opaque sync tokens, and reports the changes since any
+// token that is still covered by the history. It is safe for concurrent use.
+type ChangeLog struct {
+	mu      sync.Mutex
+	epoch   string            // distinguishes this log from any other, e.g. after a restart
+	seq     uint64            // the sequence number of the latest change
+	floor   uint64            // tokens older than this have expired
+	limit   int               // the maximum number of changes retained
+	history []logEntry        // oldest first
+	members map[string]uint64 // the resources that currently exist
+}
+
+type logEntry struct {
+	seq uint64
+	Change
+}
+
+// NewChangeLog returns an empty change log that retains up to limit changes. When
+// more changes are recorded, the oldest are discarded and tokens that depend on them
+// expire. If limit is not positive, the history is unbounded.
+func NewChangeLog(limit int) *ChangeLog {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &ChangeLog{
+		epoch:   hex.EncodeToString(b),
+		limit:   limit,
+		members: make(map[string]uint64),
+	}
+}
+
+// Record adds a change to the log and returns the new sync token.
+func (l *ChangeLog) Record(uid string, kind ChangeKind) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	l.history = append(l.history, logEntry{seq: l.seq, Change: Change{UID: uid, Kind: kind}})
+
+	if kind == Deleted {
+		delete(l.members, uid)
+	} else {
+		l.members[uid] = l.seq
+	}
+
+	if l.limit > 0 && len(l.history) > l.limit {
+		n := len(l.history) - l.limit
+		l.floor = l.history[n-1].seq
+		l.history = append(l.history[:0], l.history[n:]...)
+	}
+
+	return l.token()
+}
+
+// Token returns the current sync token.
+func (l *ChangeLog) Token() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token()
+}
+
+func (l *ChangeLog) token() string {
+	return tokenPrefix + l.epoch + ":" + strconv.FormatUint(l.seq, 10)
+}
+
+// Since returns the changes made after the token was issued, along with the current
+// token. There is at most one change per UID: a resource that was added and then
+// modified is reported as added.
+//
+// If the token is blank, all the resources that currently exist are reported as added.
+// If the token was not issued by this log, or has expired, ErrInvalidSyncToken is
+// returned.
+func (l *ChangeLog) Since(token string) ([]Change, string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if token == "" {
+		changes := make([]Change, 0, len(l.members))
+		for uid := range l.members {
+			changes = append(changes, Change{UID: uid, Kind: Added})
+		}
+		sort.Slice(changes, func(i, j int) bool { return changes[i].UID < changes[j].UID })
+		return changes, l.token(), nil
+	}
+
+	since, err := l.parseToken(token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var changes []Change
+	index := make(map[string]int)
+	for _, e := range l.history {
+		if e.seq <= since {
+			continue
+		}
+
+		i, seen := index[e.UID]
+		switch {
+		case !seen:
+			index[e.UID] = len(changes)
+			changes = append(changes, e.Change)
+		case changes[i].Kind == Added && e.Kind == Modified:
+			// still reported as added
+		default:
+			changes[i].Kind = e.Kind
+		}
+	}
+
+	return changes, l.token(), nil
+}
+
+func (l *ChangeLog) parseToken(token string) (uint64, error) {
+	rest, ok := strings.CutPrefix(token, tokenPrefix)
+	if !ok {
+		return 0, ErrInvalidSyncToken
+	}
+
+	epoch, num, ok := strings.Cut(rest, ":")
+	if !ok || epoch != l.epoch {
+		return 0, ErrInvalidSyncToken
+	}
+
+	seq, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || seq < l.floor || seq > l.seq {
+		return 0, ErrInvalidSyncToken
+	}
+
+	return seq, nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// syncCollection is the body of a sync-collection REPORT request.
+type syncCollection struct {
+	XMLName   xml.Name `xml:"DAV: sync-collection"`
+	SyncToken string   `xml:"DAV: sync-token"`
+	SyncLevel string   `xml:"DAV: sync-level"`
+}
+
+// SyncHandler answers sync-collection REPORT requests for one calendar collection,
+// using a ChangeLog. Other requests are answered with 405 Method Not Allowed, so
+// typically the handler is called only for REPORT requests.
+type SyncHandler struct {
+	// Log holds the changes to the collection.
+	Log *ChangeLog
+
+	// Href returns the path of the resource that holds a UID. It is required.
+	Href func(uid string) string
+
+	// ETag returns the current entity tag of the resource that holds a UID.
+	// It is optional.
+	ETag func(uid string) string
+}
+
+// ServeHTTP implements http.Handler.
+func (h SyncHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "REPORT" {
+		w.Header().Set("Allow", "REPORT")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := syncCollection{}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.SyncLevel != "" && strings.TrimSpace(req.SyncLevel) != "1" {
+		// only the members of the collection are supported, not infinite depth
+		preconditionFailed(w, "number-of-matches-within-limits")
+		return
+	}
+
+	changes, token, err := h.Log.Since(strings.TrimSpace(req.SyncToken))
+	if err != nil {
+		preconditionFailed(w, "valid-sync-token")
+		return
+	}
+
+	ms := multistatus{SyncToken: token}
+	for _, c := range changes {
+		resp := response{Href: h.Href(c.UID)}
+		if c.Kind == Deleted {
+			resp.Status = "HTTP/1.1 404 Not Found"
+		} else {
+			ps := propstat{Status: "HTTP/1.1 200 OK"}
+			if h.ETag != nil {
+				ps.Prop.GetETag = h.ETag(c.UID)
+			}
+			resp.Propstat = []propstat{ps}
+		}
+		ms.Responses = append(ms.Responses, resp)
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(ms)
+}
+
+// preconditionFailed sends a 403 response with a DAV:error body naming the precondition.
+func preconditionFailed(w http.ResponseWriter, condition string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	fmt.Fprintf(w, `%s<d:error xmlns:d="DAV:"><d:%s/></d:error>`, xml.Header, condition)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// SyncResult holds the outcome of a sync-collection REPORT.
+type SyncResult struct {
+	// Token is the new sync token, to be used next time.
+	Token string
+	// Updated lists the objects that were added or modified. Their Data is set only
+	// if the server supplied it; otherwise use GetObject to fetch them.
+	Updated []Object
+	// Deleted lists the paths of the objects that were deleted.
+	Deleted []string
+}
+
+// syncCollectionRequest is the template for a sync-collection REPORT.
+const syncCollectionRequest = `<?xml version="1.0" encoding="utf-8"?>
+<d:sync-collection xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:sync-token>%s</d:sync-token>
+  <d:sync-level>1</d:sync-level>
+  <d:prop>
+    <d:getetag/>
+  </d:prop>
+</d:sync-collection>`
+
+// SyncCollection asks the server for the changes to a calendar collection since the
+// token was issued. Use a blank token for the initial synchronisation.
+//
+// If the server no longer recognises the token, ErrInvalidSyncToken is returned; the
+// client should then synchronise again from a blank token.
+func (c *Client) SyncCollection(ctx context.Context, calendarPath, token string) (*SyncResult, error) {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(token))
+	body := fmt.Sprintf(syncCollectionRequest, buf.String())
+
+	ms, err := c.report(ctx, calendarPath, body)
+	if se, ok := err.(*StatusError); ok && se.validSyncTokenFailed {
+		return nil, ErrInvalidSyncToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Token: ms.SyncToken}
+	for _, r := range ms.Responses {
+		if strings.Contains(r.Status, " 404") {
+			result.Deleted = append(result.Deleted, r.Href)
+			continue
+		}
+
+		p := r.props()
+		obj := Object{Path: r.Href, ETag: p.GetETag}
+		if p.CalendarData != "" {
+			objs, err := objects(&multistatus{Responses: []response{r}})
+			if err != nil {
+				return nil, err
+			}
+			obj = objs[0]
+		}
+		result.Updated = append(result.Updated, obj)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package caldav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChangeLogSince(t *testing.T) {
+	l := NewChangeLog(0)
+	t0 := l.Token()
+
+	l.Record("a", Added)
+	l.Record("b", Added)
+	t1 := l.Token()
+
+	l.Record("a", Modified)
+	l.Record("c", Added)
+	l.Record("c", Modified)
+	l.Record("b", Deleted)
+
+	changes, t2, err := l.Since(t0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []Change{{"a", Added}, {"b", Deleted}, {"c", Added}}
+	if !reflect.DeepEqual(changes, exp) {
+		t.Errorf("expected %v but got %v", exp, changes)
+	}
+
+	changes, _, _ = l.Since(t1)
+	exp = []Change{{"a", Modified}, {"c", Added}, {"b", Deleted}}
+	if !reflect.DeepEqual(changes, exp) {
+		t.Errorf("expected %v but got %v", exp, changes)
+	}
+
+	changes, t3, _ := l.Since(t2)
+	if len(changes) != 0 || t3 != t2 {
+		t.Errorf("got %v %s", changes, t3)
+	}
+
+	changes, _, _ = l.Since("")
+	exp = []Change{{"a", Added}, {"c", Added}}
+	if !reflect.DeepEqual(changes, exp) {
+		t.Errorf("expected %v but got %v", exp, changes)
+	}
+}
+
+func TestChangeLogExpiry(t *testing.T) {
+	l := NewChangeLog(2)
+	t0 := l.Token()
+	l.Record("a", Added)
+	t1 := l.Token()
+	l.Record("b", Added)
+	l.Record("c", Added)
+
+	if _, _, err := l.Since(t0); err != ErrInvalidSyncToken {
+		t.Errorf("got %v", err)
+	}
+
+	changes, _, err := l.Since(t1)
+	if err != nil || len(changes) != 2 {
+		t.Errorf("got %v %v", changes, err)
+	}
+
+	other := NewChangeLog(0)
+	cases := []string{other.Token(), "urn:x-ical2:sync:", "http://example.com/token", t1 + "0"}
+	for i, c := range cases {
+		if _, _, err := l.Since(c); err != ErrInvalidSyncToken {
+			t.Errorf("%d: got %v", i, err)
+		}
+	}
+}
+
+func TestSyncCollection(t *testing.T) {
+	l := NewChangeLog(3)
+	l.Record("event-1", Added)
+	l.Record("event-2", Added)
+
+	h := SyncHandler{
+		Log:  l,
+		Href: func(uid string) string { return "/cal/work/" + uid + ".ics" },
+		ETag: func(uid string) string { return `"` + uid + `"` },
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/cal/work/", h)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, _ := NewClient(srv.Client(), srv.URL)
+	ctx := context.Background()
+
+	r1, err := c.SyncCollection(ctx, "/cal/work/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r1.Updated) != 2 || r1.Updated[0].Path != "/cal/work/event-1.ics" || r1.Updated[0].ETag != `"event-1"` {
+		t.Errorf("got %+v", r1)
+	}
+
+	l.Record("event-1", Deleted)
+
+	r2, err := c.SyncCollection(ctx, "/cal/work/", r1.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r2.Updated) != 0 || !reflect.DeepEqual(r2.Deleted, []string{"/cal/work/event-1.ics"}) || r2.Token == r1.Token {
+		t.Errorf("got %+v", r2)
+	}
+
+	for i := 3; i < 7; i++ {
+		l.Record("event-"+strings.Repeat("x", i), Added)
+	}
+
+	_, err = c.SyncCollection(ctx, "/cal/work/", r2.Token)
+	if err != ErrInvalidSyncToken {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// multistatus is the WebDAV multi-status response body.
+// https://tools.ietf.org/html/rfc4918#section-14.16
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"DAV: response"`
+	SyncToken string     `xml:"DAV: sync-token,omitempty"`
+}
+
+type response struct {
+	Href     string     `xml:"DAV: href"`
+	Propstat []propstat `xml:"DAV: propstat"`
+	Status   string     `xml:"DAV: status,omitempty"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type prop struct {
+	CurrentUserPrincipal *href                `xml:"DAV: current-user-principal,omitempty"`
+	CalendarHomeSet      *href                `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set,omitempty"`
+	ResourceType         *resourceType        `xml:"DAV: resourcetype,omitempty"`
+	DisplayName          string               `xml:"DAV: displayname,omitempty"`
+	CalendarDescription  string               `xml:"urn:ietf:params:xml:ns:caldav calendar-description,omitempty"`
+	SupportedComponents  *supportedComponents `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set,omitempty"`
+	GetCTag              string               `xml:"http://calendarserver.org/ns/ getctag,omitempty"`
+	GetETag              string               `xml:"DAV: getetag,omitempty"`
+	CalendarData         string               `xml:"urn:ietf:params:xml:ns:caldav calendar-data,omitempty"`
+}
+
+type href struct {
+	Href string `xml:"DAV: href"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"DAV: collection,omitempty"`
+	Calendar   *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar,omitempty"`
+}
+
+type supportedComponents struct {
+	Comp []comp `xml:"urn:ietf:params:xml:ns:caldav comp"`
+}
+
+type comp struct {
+	Name string `xml:"name,attr"`
+}
+
+// ok tests whether a propstat has a 2xx status.
+func (ps propstat) ok() bool {
+	return ps.Status == "" || strings.Contains(ps.Status, " 2")
+}
+
+// props returns the successful properties of a response, merged into one.
+func (r response) props() prop {
+	var merged prop
+	for _, ps := range r.Propstat {
+		if !ps.ok() {
+			continue
+		}
+		p := ps.Prop
+		if p.CurrentUserPrincipal != nil {
+			merged.CurrentUserPrincipal = p.CurrentUserPrincipal
+		}
+		if p.CalendarHomeSet != nil {
+			merged.CalendarHomeSet = p.CalendarHomeSet
+		}
+		if p.ResourceType != nil {
+			merged.ResourceType = p.ResourceType
+		}
+		if p.SupportedComponents != nil {
+			merged.SupportedComponents = p.SupportedComponents
+		}
+		merged.DisplayName = first(merged.DisplayName, p.DisplayName)
+		merged.CalendarDescription = first(merged.CalendarDescription, p.CalendarDescription)
+		merged.GetCTag = first(merged.GetCTag, p.GetCTag)
+		merged.GetETag = first(merged.GetETag, p.GetETag)
+		merged.CalendarData = first(merged.CalendarData, p.CalendarData)
+	}
+	return merged
+}
+
+func first(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
+
+//-------------------------------------------------------------------------------------------------
+
+const propfindPrincipal = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:current-user-principal/>
+  </d:prop>
+</d:propfind>`
+
+const propfindHomeSet = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <c:calendar-home-set/>
+  </d:prop>
+</d:propfind>`
+
+const propfindCalendars = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">
+  <d:prop>
+    <d:resourcetype/>
+    <d:displayname/>
+    <c:calendar-description/>
+    <c:supported-calendar-component-set/>
+    <cs:getctag/>
+  </d:prop>
+</d:propfind>`
+
+// calendarQuery is the template for a calendar-query REPORT with a time-range filter.
+// https://tools.ietf.org/html/rfc4791#section-7.8
+const calendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <d:getetag/>
+    <c:calendar-data/>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="%s">
+        <c:time-range start="%s" end="%s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
@@ -0,0 +1,196 @@
+package ical2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/rickb777/ical2/parameter"
+	"io"
+	"sort"
+	"strings"
+)
+
+// defaultValueTypes gives the value type of each property when there is no VALUE
+// parameter. A VALUE parameter that repeats the default is redundant.
+var defaultValueTypes = map[string]string{
+	"ATTACH":           "URI",
+	"ATTENDEE":         "CAL-ADDRESS",
+	"CONFERENCE":       "URI",
+	"CREATED":          "DATE-TIME",
+	"DTEND":            "DATE-TIME",
+	"DTSTAMP":          "DATE-TIME",
+	"DTSTART":          "DATE-TIME",
+	"DUE":              "DATE-TIME",
+	"DURATION":         "DURATION",
+	"EXDATE":           "DATE-TIME",
+	"FREEBUSY":         "PERIOD",
+	"GEO":              "FLOAT",
+	"IMAGE":            "URI",
+	"LAST-MODIFIED":    "DATE-TIME",
+	"ORGANIZER":        "CAL-ADDRESS",
+	"PRIORITY":         "INTEGER",
+	"RDATE":            "DATE-TIME",
+	"RECURRENCE-ID":    "DATE-TIME",
+	"REFRESH-INTERVAL": "DURATION",
+	"REPEAT":           "INTEGER",
+	"RRULE":            "RECUR",
+	"SEQUENCE":         "INTEGER",
+	"SOURCE":           "URI",
+	"TRIGGER":          "DURATION",
+	"URL":              "URI",
+}
+
+// defaultValueType returns the default value type of a property. Properties
+// not listed, including non-standard ones, default to TEXT.
+func defaultValueType(property string) string {
+	if vt, exists := defaultValueTypes[property]; exists {
+		return vt
+	}
+	return "TEXT"
+}
+
+// defaultParameters are parameter values that RFC-5545 specifies as the default,
+// so they are redundant.
+var defaultParameters = map[string]string{
+	"CUTYPE":   "INDIVIDUAL",
+	"ENCODING": "8BIT",
+	"FBTYPE":   "BUSY",
+	"PARTSTAT": "NEEDS-ACTION",
+	"RELATED":  "START",
+	"RELTYPE":  "PARENT",
+	"ROLE":     "REQ-PARTICIPANT",
+	"RSVP":     "FALSE",
+}
+
+// enumeratedParameters have values that are case-insensitive tokens.
+var enumeratedParameters = map[string]bool{
+	"CUTYPE":   true,
+	"DISPLAY":  true,
+	"ENCODING": true,
+	"FBTYPE":   true,
+	"FEATURE":  true,
+	"PARTSTAT": true,
+	"RANGE":    true,
+	"RELATED":  true,
+	"RELTYPE":  true,
+	"ROLE":     true,
+	"RSVP":     true,
+	"VALUE":    true,
+}
+
+// setParameters have multiple values whose order has no meaning.
+var setParameters = map[string]bool{
+	"DELEGATED-FROM": true,
+	"DELEGATED-TO":   true,
+	"DISPLAY":        true,
+	"FEATURE":        true,
+	"MEMBER":         true,
+}
+
+// EncodeCanonical encodes the calendar in a canonical ICS form, writing it to some
+// Writer. Calendars that have the same content always have the same canonical form,
+// however their components and parameters were assembled.
+//
+// In the canonical form, top-level components are sorted by UID, then RECURRENCE-ID;
+// nested components and the properties within each component are sorted; parameters
+// are sorted by name; enumerated parameter values are upper case; and parameters
+// and properties that repeat the default value, such as VALUE=DATE-TIME on DTSTART
+// and CALSCALE:GREGORIAN, are omitted.
+// The line endings are "\r\n".
+func (c *VCalendar) EncodeCanonical(w io.Writer) error {
+	e := NewEncoder(w)
+	e.Canonical = true
+	return e.Encode(c)
+}
+
+// Hash returns the SHA-256 hash of the canonical encoding as a hex string. It is
+// suitable for use as an entity tag or for finding duplicate calendars.
+func (c *VCalendar) Hash() (string, error) {
+	buf := &bytes.Buffer{}
+	if err := c.EncodeCanonical(buf); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalise rewrites the component, and those it contains, in canonical form.
+func (c *rawComponent) canonicalise(top bool) {
+	var props []rawProperty
+	for _, p := range c.Properties {
+		if p.Name == "CALSCALE" && strings.EqualFold(p.Value, "GREGORIAN") {
+			continue // the default
+		}
+		p.Parameters = canonicalParameters(p.Name, p.Parameters)
+		props = append(props, p)
+	}
+	c.Properties = props
+
+	sort.SliceStable(c.Properties, func(i, j int) bool {
+		return c.Properties[i].sortKey() < c.Properties[j].sortKey()
+	})
+
+	keys := make(map[*rawComponent]string)
+	for _, sub := range c.Components {
+		sub.canonicalise(false)
+		keys[sub] = sub.String()
+		if top {
+			uid, rid := sub.identity()
+			keys[sub] = uid + "\x00" + rid + "\x00" + keys[sub]
+		}
+	}
+
+	sort.SliceStable(c.Components, func(i, j int) bool {
+		return keys[c.Components[i]] < keys[c.Components[j]]
+	})
+}
+
+func (p rawProperty) sortKey() string {
+	buf := &strings.Builder{}
+	buf.WriteString(p.Name)
+	buf.WriteByte(0)
+	buf.WriteString(p.Value)
+	buf.WriteByte(0)
+	p.Parameters.WriteTo(buf)
+	return buf.String()
+}
+
+func canonicalParameters(property string, pp parameter.Parameters) parameter.Parameters {
+	var result parameter.Parameters
+
+	for _, p := range pp {
+		p.Key = strings.ToUpper(p.Key)
+
+		if enumeratedParameters[p.Key] {
+			p.Value = strings.ToUpper(p.Value)
+			p.Others = append([]string(nil), p.Others...) // not shared with the original
+			for i, v := range p.Others {
+				p.Others[i] = strings.ToUpper(v)
+			}
+		}
+
+		if setParameters[p.Key] && len(p.Others) > 0 {
+			values := append([]string{p.Value}, p.Others...)
+			sort.Strings(values)
+			p.Value, p.Others = values[0], values[1:]
+		}
+
+		if len(p.Others) == 0 {
+			if p.Key == "VALUE" && p.Value == defaultValueType(property) {
+				continue
+			}
+			if def, exists := defaultParameters[p.Key]; exists && p.Value == def {
+				continue
+			}
+		}
+
+		result = append(result, p)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+
+	return result
+}
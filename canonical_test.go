@@ -0,0 +1,155 @@
+package ical2_test
+
+import (
+	"bytes"
+	"github.com/rickb777/ical2"
+	"strings"
+	"testing"
+)
+
+const canonicalA = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+X-B:2
+X-A:1
+BEGIN:VEVENT
+DTSTART;VALUE=DATE-TIME:20240103T100000Z
+DTSTAMP:20240101T000000Z
+UID:b
+SUMMARY;VALUE=TEXT:Review
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20240102T100000Z
+DTSTAMP:20240101T000000Z
+UID:a
+ATTENDEE;CN=X;ROLE=REQ-PARTICIPANT;PARTSTAT=accepted:mailto:x@y
+ATTENDEE;RSVP=TRUE;DELEGATED-TO="mailto:q@y","mailto:p@y":mailto:w@y
+SUMMARY:Planning
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Later
+TRIGGER:-PT5M
+END:VALARM
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Soon
+TRIGGER;RELATED=START:-PT10M
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`
+
+const canonicalB = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+X-A:1
+X-B:2
+BEGIN:VEVENT
+DTSTART:20240102T100000Z
+DTSTAMP:20240101T000000Z
+UID:a
+ATTENDEE;DELEGATED-TO="mailto:p@y","mailto:q@y";RSVP=TRUE:mailto:w@y
+ATTENDEE;PARTSTAT=ACCEPTED;CN=X:mailto:x@y
+SUMMARY:Planning
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Soon
+TRIGGER:-PT10M
+END:VALARM
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Later
+TRIGGER:-PT5M
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20240103T100000Z
+DTSTAMP:20240101T000000Z
+UID:b
+SUMMARY:Review
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestCanonicalHash(t *testing.T) {
+	a, err := ical2.Decode(strings.NewReader(canonicalA))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ical2.Decode(strings.NewReader(canonicalB))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ha, err := a.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hb, err := b.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ha != hb || len(ha) != 64 {
+		buf := &bytes.Buffer{}
+		a.EncodeCanonical(buf)
+		buf.WriteString("----\n")
+		b.EncodeCanonical(buf)
+		t.Errorf("%s != %s\n%s", ha, hb, buf)
+	}
+
+	b.VComponent = b.VComponent[:1]
+	if hc, _ := b.Hash(); hc == ha {
+		t.Errorf("expected different hashes")
+	}
+}
+
+func TestEncodeCanonical(t *testing.T) {
+	c, err := ical2.Decode(strings.NewReader(canonicalA))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := c.EncodeCanonical(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := strings.ReplaceAll(`BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+X-A:1
+X-B:2
+BEGIN:VEVENT
+ATTENDEE;DELEGATED-TO="mailto:p@y","mailto:q@y";RSVP=TRUE:mailto:w@y
+ATTENDEE;CN=X;PARTSTAT=ACCEPTED:mailto:x@y
+DTSTAMP:20240101T000000Z
+DTSTART:20240102T100000Z
+SUMMARY:Planning
+UID:a
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Later
+TRIGGER:-PT5M
+END:VALARM
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Soon
+TRIGGER:-PT10M
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+DTSTAMP:20240101T000000Z
+DTSTART:20240103T100000Z
+SUMMARY:Review
+UID:b
+END:VEVENT
+END:VCALENDAR
+`, "\n", "\r\n")
+
+	if buf.String() != exp {
+		t.Errorf("expected\n%s\nbut got\n%s", exp, buf)
+	}
+}
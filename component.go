@@ -0,0 +1,190 @@
+package ical2
+
+import (
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/value"
+	"strings"
+)
+
+// Component is a generic calendar component. It can represent any component,
+// whether IANA-registered, X-named or newer than this package, such as VTODO,
+// VJOURNAL or VTIMEZONE. The decoder uses it for the components that are not
+// modelled otherwise, so that they are not lost.
+type Component struct {
+	// Name is the component name, e.g. "VTODO".
+	Name string
+
+	// Properties are written in order.
+	Properties []Property
+
+	// Components are the child components, written after the properties.
+	Components []VComponent
+}
+
+// Property is a property of a generic component.
+type Property struct {
+	// Name is the property name, e.g. "SUMMARY".
+	Name string
+
+	// Parameters are written before any parameters held by the value.
+	Parameters parameter.Parameters
+
+	// Value is the property value; it may be typed, e.g. value.DateTimeValue,
+	// or raw, i.e. value.RawValue, which is written verbatim.
+	Value ics.Valuer
+}
+
+// IsAlarm allows a generic VALARM component to be used as an alarm, e.g. for an
+// ACTION that is not modelled by this package.
+func (c *Component) IsAlarm() {}
+
+// NewComponent constructs a new generic component.
+func NewComponent(name string) *Component {
+	return &Component{Name: strings.ToUpper(name)}
+}
+
+// Add appends a property to the component.
+// The Component is modified and is returned.
+func (c *Component) Add(name string, v ics.Valuer, params ...parameter.Parameter) *Component {
+	c.Properties = append(c.Properties, Property{Name: strings.ToUpper(name), Parameters: params, Value: v})
+	return c
+}
+
+// With appends a child component.
+// The Component is modified and is returned.
+func (c *Component) With(child VComponent) *Component {
+	c.Components = append(c.Components, child)
+	return c
+}
+
+// Get finds the first property with a given name. The boolean result is false if
+// there is no such property.
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Properties {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Validate checks the names of the component and its properties, its extension
+// properties and its child components, returning every problem found.
+func (c *Component) Validate() Problems {
+	return c.validate("", 0, value.TextValue{})
+}
+
+func (c *Component) validate(parent string, index int, method value.TextValue) Problems {
+	v := &validation{parent: parent, name: c.Name, index: index}
+
+	if p, exists := c.Get("UID"); exists {
+		v.uid = value.Text(textOf(p.Value))
+	}
+
+	if c.Name == "" {
+		v.add("", InvalidName, SeverityError, "Name is required")
+	} else if !ianaToken.MatchString(c.Name) {
+		v.add("", InvalidName, SeverityError, "%q is not a valid component name", c.Name)
+	}
+
+	key := c.Name
+	if p, exists := c.Get("ACTION"); exists && c.Name == "VALARM" {
+		key += "/" + strings.ToUpper(textOf(p.Value))
+	}
+	schema, known := Schema(key)
+
+	var extensions []Extension
+	counts := newCounter()
+	for _, p := range c.Properties {
+		name := strings.ToUpper(p.Name)
+		if err := checkPropertyName(name); err != nil {
+			v.add("", InvalidName, SeverityError, "%v", err)
+		} else if strings.HasPrefix(name, "X-") {
+			extensions = append(extensions, Extension{p.Name, p.Value})
+		} else if _, allowed := schema.Property(name); known && !allowed {
+			v.add(name, UnexpectedProperty, SeverityWarning, "%s is not expected in %s", name, c.Name)
+		}
+		counts.add(name, withParameters{p.Parameters, p.Value})
+	}
+
+	v.schema(key, counts)
+	v.extensions(extensions)
+
+	for i, child := range c.Components {
+		v.child(v.within(), child, i, method)
+	}
+
+	return v.problems
+}
+
+// EncodeIcal serialises the component to the buffer in iCalendar ics format
+// (a VComponent method).
+func (c *Component) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+	if err := c.validate("", 0, method).Err(); err != nil {
+		return err
+	}
+	return c.encodeIcal(b, method)
+}
+
+// encodeIcal writes the component without validating it first.
+func (c *Component) encodeIcal(b *ics.Buffer, method value.TextValue) error {
+	b.WriteLine("BEGIN:" + c.Name)
+
+	for _, p := range c.Properties {
+		b.WriteValuerLine(p.Value != nil, p.Name, withParameters{p.Parameters, p.Value})
+	}
+
+	for _, child := range c.Components {
+		if err := encodeValidated(b, child, method); err != nil {
+			return err
+		}
+	}
+
+	b.WriteLine("END:" + c.Name)
+
+	return b.Flush()
+}
+
+// textOf gives the text of a TEXT or raw value, or blank for other values.
+func textOf(v ics.Valuer) string {
+	switch x := v.(type) {
+	case value.TextValue:
+		return x.Value
+	case value.RawValue:
+		return x.Value
+	}
+	return ""
+}
+
+// withParameters writes extra parameters ahead of a value.
+type withParameters struct {
+	parameters parameter.Parameters
+	value      ics.Valuer
+}
+
+func (v withParameters) IsDefined() bool {
+	return ics.IsDefined(v.value)
+}
+
+// Params gets the extra parameters followed by those of the value.
+func (v withParameters) Params() parameter.Parameters {
+	return append(v.parameters[:len(v.parameters):len(v.parameters)], parametersOf(v.value)...)
+}
+
+func (v withParameters) WriteTo(w ics.StringWriter) error {
+	v.parameters.WriteTo(w)
+	return v.value.WriteTo(w)
+}
+
+// componentOf converts a raw component and its children into generic components.
+func componentOf(raw *rawComponent) *Component {
+	c := &Component{Name: raw.Name}
+	for _, p := range raw.Properties {
+		c.Properties = append(c.Properties, Property{Name: p.Name, Parameters: p.Parameters, Value: value.Raw(p.Value)})
+	}
+	for _, sub := range raw.Components {
+		c.Components = append(c.Components, componentOf(sub))
+	}
+	return c
+}
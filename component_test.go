@@ -0,0 +1,137 @@
+package ical2_test
+
+import (
+	"fmt"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ExampleComponent() {
+	dt := time.Date(2014, time.Month(1), 1, 7, 0, 0, 0, time.UTC)
+
+	todo := ical2.NewComponent("VTODO").
+		Add("UID", value.Text("t1")).
+		Add("DTSTAMP", value.TStamp(dt)).
+		Add("DUE", value.Date(dt.AddDate(0, 0, 7))).
+		Add("SUMMARY", value.Text("Submit report"), parameter.Language("en")).
+		Add("X-PRIORITY-LABEL", value.Raw("urgent")).
+		With(&ical2.VDisplayAlarm{Description: value.Text("Report due"), Trigger: value.Duration("-P1D")})
+
+	c := ical2.NewVCalendar("-//My App//Event Calendar//EN").With(todo)
+	fmt.Println(c.String())
+
+	// Output:
+	// BEGIN:VCALENDAR
+	// PRODID:-//My App//Event Calendar//EN
+	// VERSION:2.0
+	// CALSCALE:GREGORIAN
+	// BEGIN:VTODO
+	// UID:t1
+	// DTSTAMP:20140101T070000Z
+	// DUE;VALUE=DATE:20140108
+	// SUMMARY;LANGUAGE=en:Submit report
+	// X-PRIORITY-LABEL:urgent
+	// BEGIN:VALARM
+	// ACTION:DISPLAY
+	// DESCRIPTION:Report due
+	// TRIGGER;VALUE=DURATION:-P1D
+	// END:VALARM
+	// END:VTODO
+	// END:VCALENDAR
+}
+
+const unmodelledCalendar = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+BEGIN:VTIMEZONE
+TZID:Europe/London
+BEGIN:STANDARD
+DTSTART:19701025T020000
+RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0000
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VJOURNAL
+UID:j1
+DTSTAMP:20240101T000000Z
+DESCRIPTION;LANGUAGE=en:Notes\, with a comma
+END:VJOURNAL
+BEGIN:X-CUSTOM
+X-THING:1
+END:X-CUSTOM
+END:VCALENDAR
+`
+
+func TestDecodeUnmodelledComponents(t *testing.T) {
+	c, err := ical2.Decode(strings.NewReader(unmodelledCalendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.VComponent) != 3 {
+		t.Fatalf("got %d components", len(c.VComponent))
+	}
+
+	tz, ok := c.VComponent[0].(*ical2.Component)
+	if !ok || tz.Name != "VTIMEZONE" || len(tz.Components) != 1 {
+		t.Fatalf("got %#v", c.VComponent[0])
+	}
+
+	if p, ok := tz.Get("tzid"); !ok || p.Value.(value.RawValue).Value != "Europe/London" {
+		t.Errorf("got %v", p)
+	}
+
+	if s := c.String(); s != unmodelledCalendar {
+		t.Errorf("got\n%s", s)
+	}
+}
+
+func TestComponentErrors(t *testing.T) {
+	cases := []*ical2.Component{
+		{},
+		ical2.NewComponent("VTODO").Add("BAD NAME", value.Text("x")),
+		ical2.NewComponent("VTODO\r\nBEGIN:VEVENT"),
+		ical2.NewComponent("VTODO").With(ical2.NewComponent("X-A:B")),
+	}
+
+	for i, comp := range cases {
+		c := ical2.NewVCalendar("-//Test//EN").With(comp)
+		buf := &strings.Builder{}
+		if err := c.EncodePlain(buf); err == nil || strings.Contains(buf.String(), "BEGIN:V") {
+			t.Errorf("%d: got %v\n%s", i, err, buf.String())
+		}
+	}
+}
+
+const outlookCalendar = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+BEGIN:VTODO
+UID:t1
+DTSTAMP:20240101T000000Z
+SUMMARY:Review
+X-ALT-DESC;FMTTYPE=text/html:<p>Review</p>
+X-MS-OLK-ORIGINALSTART;TZID=Europe/Paris:20240102T090000
+END:VTODO
+END:VCALENDAR
+`
+
+func TestComponentExtensionParametersRoundTrip(t *testing.T) {
+	c, err := ical2.Decode(strings.NewReader(outlookCalendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ps := c.Validate(); len(ps) > 0 {
+		t.Errorf("got %v", ps)
+	}
+
+	if s := c.String(); s != outlookCalendar {
+		t.Errorf("got\n%s", s)
+	}
+}
@@ -0,0 +1,428 @@
+package ical2
+
+import (
+	"fmt"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/value"
+	"io"
+	"strings"
+)
+
+// rawProperty is a property as read from a content line, before its value
+// has been interpreted.
+type rawProperty struct {
+	Name       string
+	Parameters parameter.Parameters
+	Value      string
+	line       int
+}
+
+// rawComponent is a component as read from a stream, before its properties
+// have been interpreted.
+type rawComponent struct {
+	Name       string
+	Properties []rawProperty
+	Components []*rawComponent
+}
+
+// readComponents reads all the top-level components in a stream.
+func readComponents(r io.Reader) ([]*rawComponent, error) {
+	lr := ics.NewLineReader(r)
+
+	var top []*rawComponent
+	var stack []*rawComponent
+
+	for {
+		line, err := lr.ReadLine()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		name, params, val, err := ics.SplitContentLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lr.Line(), err)
+		}
+
+		switch name {
+		case "BEGIN":
+			c := &rawComponent{Name: strings.ToUpper(val)}
+			if len(stack) == 0 {
+				top = append(top, c)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			}
+			stack = append(stack, c)
+
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(val) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", lr.Line(), val)
+			}
+			stack = stack[:len(stack)-1]
+
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: %s is outside any component", lr.Line(), name)
+			}
+
+			pp, err := parameter.Parse(params)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lr.Line(), err)
+			}
+
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, rawProperty{Name: name, Parameters: pp, Value: val, line: lr.Line()})
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+
+	return top, nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Decode reads an iCalendar stream and returns the calendar it contains. If the stream
+// contains more than one calendar, only the first is returned; see DecodeAll.
+//
+// Calendar components that are not modelled by this package, such as VTODO and
+// VTIMEZONE, are decoded as generic Components, as are alarms with actions that
+// are not modelled, alarms with properties that their type does not hold (e.g. a
+// DISPLAY alarm with a SUMMARY) and components nested within events other than alarms.
+// Properties that are not modelled, such as X- properties, are kept as Extensions.
+//
+// Date-times with a TZID parameter are read using the time zone database or,
+// failing that, the calendar's VTIMEZONE components. An error wrapping
+// value.ErrUnknownTimeZone is returned if the time zone is not found in either.
+func Decode(r io.Reader) (*VCalendar, error) {
+	cc, err := DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cc) == 0 {
+		return nil, fmt.Errorf("no VCALENDAR found")
+	}
+
+	return cc[0], nil
+}
+
+// DecodeAll reads an iCalendar stream and returns all the calendars it contains.
+func DecodeAll(r io.Reader) ([]*VCalendar, error) {
+	top, err := readComponents(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cc []*VCalendar
+	for _, raw := range top {
+		if raw.Name != "VCALENDAR" {
+			return nil, fmt.Errorf("expected VCALENDAR but got %s", raw.Name)
+		}
+
+		c, err := decodeCalendar(raw)
+		if err != nil {
+			return nil, err
+		}
+		cc = append(cc, c)
+	}
+
+	return cc, nil
+}
+
+func decodeCalendar(raw *rawComponent) (*VCalendar, error) {
+	c := &VCalendar{}
+	zones := timeZonesOf(raw)
+
+	for _, p := range raw.Properties {
+		var err error
+		switch p.Name {
+		case "PRODID":
+			c.ProdId = value.ParseText(p.Parameters, p.Value)
+		case "VERSION":
+			c.Version = value.ParseText(p.Parameters, p.Value)
+		case "CALSCALE":
+			c.CalScale = value.ParseText(p.Parameters, p.Value)
+		case "METHOD":
+			c.Method = value.ParseText(p.Parameters, p.Value)
+		case "NAME":
+			c.Name = value.ParseText(p.Parameters, p.Value)
+		case "DESCRIPTION":
+			c.Description = value.ParseText(p.Parameters, p.Value)
+		case "URL":
+			c.URL = value.ParseText(p.Parameters, p.Value)
+		case "SOURCE":
+			c.Source = value.ParseURI(p.Parameters, p.Value)
+		case "LAST-MODIFIED":
+			c.LastModified, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "RECURRENCE-ID":
+			c.RecurrenceId, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "REFRESH-INTERVAL":
+			c.RefreshInterval, err = value.ParseDuration(p.Parameters, p.Value)
+		case "COLOR":
+			c.Color = value.ParseText(p.Parameters, p.Value)
+		default:
+			c.Extensions = append(c.Extensions, Registry.parseExtension(p))
+		}
+
+		if err != nil {
+			return nil, p.wrap(err)
+		}
+	}
+
+	for _, sub := range raw.Components {
+		var vc VComponent
+		var err error
+		switch sub.Name {
+		case "VEVENT":
+			vc, err = decodeEvent(sub, zones)
+		case "VFREEBUSY":
+			vc, err = decodeFreeBusy(sub, zones)
+		case "VPATCH":
+			vc, err = decodeVPatch(sub)
+		default:
+			vc = componentOf(sub)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		c.VComponent = append(c.VComponent, vc)
+	}
+
+	return c, nil
+}
+
+func decodeEvent(raw *rawComponent, zones value.TimeZones) (*VEvent, error) {
+	e := &VEvent{}
+
+	for _, p := range raw.Properties {
+		var err error
+		switch p.Name {
+		case "DTSTART":
+			e.Start, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "DTEND":
+			e.End, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "DURATION":
+			e.Duration, err = value.ParseDuration(p.Parameters, p.Value)
+		case "CREATED":
+			e.Created, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "DTSTAMP":
+			e.DTStamp, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "LAST-MODIFIED":
+			e.LastModified, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "EXDATE":
+			var v value.DateTimeValue
+			v, err = zones.ParseDateTime(p.Parameters, p.Value)
+			e.ExceptionDate = append(e.ExceptionDate, v)
+		case "RDATE":
+			var v value.Temporal
+			v, err = zones.ParseTemporal(p.Parameters, p.Value)
+			e.RecurrenceDate = append(e.RecurrenceDate, v)
+		case "RRULE":
+			e.RecurrenceRule, err = value.ParseRecurrence(p.Parameters, p.Value)
+		case "RECURRENCE-ID":
+			e.RecurrenceId, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "CONFERENCE":
+			e.Conference = append(e.Conference, value.ParseURI(p.Parameters, p.Value))
+		case "ATTENDEE":
+			e.Attendee = append(e.Attendee, value.ParseURI(p.Parameters, p.Value))
+		case "ORGANIZER":
+			e.Organizer = value.ParseURI(p.Parameters, p.Value)
+		case "CONTACT":
+			e.Contact = append(e.Contact, value.ParseText(p.Parameters, p.Value))
+		case "SUMMARY":
+			e.Summary = value.ParseText(p.Parameters, p.Value)
+		case "DESCRIPTION":
+			e.Description = value.ParseText(p.Parameters, p.Value)
+		case "CLASS":
+			e.Class = value.ParseText(p.Parameters, p.Value)
+		case "COMMENT":
+			e.Comment = append(e.Comment, value.ParseText(p.Parameters, p.Value))
+		case "RELATED-TO":
+			e.RelatedTo = value.ParseText(p.Parameters, p.Value)
+		case "URL":
+			e.URL = value.ParseURI(p.Parameters, p.Value)
+		case "UID":
+			e.UID = value.ParseText(p.Parameters, p.Value)
+		case "CATEGORIES":
+			e.Categories = append(e.Categories, value.ParseList(p.Parameters, p.Value))
+		case "RESOURCES":
+			e.Resources = append(e.Resources, value.ParseList(p.Parameters, p.Value))
+		case "SEQUENCE":
+			e.Sequence, err = value.ParseInteger(p.Parameters, p.Value)
+		case "PRIORITY":
+			e.Priority, err = value.ParseInteger(p.Parameters, p.Value)
+		case "STATUS":
+			e.Status = value.ParseText(p.Parameters, p.Value)
+		case "LOCATION":
+			e.Location = value.ParseText(p.Parameters, p.Value)
+		case "GEO":
+			e.Geo, err = value.ParseGeo(p.Parameters, p.Value)
+		case "TRANSP":
+			e.Transparency = value.ParseText(p.Parameters, p.Value)
+		case "COLOR":
+			e.Color = value.ParseText(p.Parameters, p.Value)
+		case "ATTACH":
+			var v value.Attachable
+			v, err = value.ParseAttachable(p.Parameters, p.Value)
+			e.Attach = append(e.Attach, v)
+		case "IMAGE":
+			var v value.Attachable
+			v, err = value.ParseAttachable(p.Parameters, p.Value)
+			e.Image = append(e.Image, v)
+		default:
+			e.Extensions = append(e.Extensions, Registry.parseExtension(p))
+		}
+
+		if err != nil {
+			return nil, p.wrap(err)
+		}
+	}
+
+	for _, sub := range raw.Components {
+		if sub.Name != "VALARM" {
+			e.Components = append(e.Components, componentOf(sub))
+			continue
+		}
+
+		alarm, err := decodeAlarm(sub, zones)
+		if err != nil {
+			return nil, err
+		}
+		e.Alarm = append(e.Alarm, alarm)
+	}
+
+	return e, nil
+}
+
+func decodeFreeBusy(raw *rawComponent, zones value.TimeZones) (*VFreeBusy, error) {
+	e := &VFreeBusy{}
+
+	for _, p := range raw.Properties {
+		var err error
+		switch p.Name {
+		case "UID":
+			e.UID = value.ParseText(p.Parameters, p.Value)
+		case "DTSTAMP":
+			e.DTStamp, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "DTSTART":
+			e.Start, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "DTEND":
+			e.End, err = zones.ParseDateTime(p.Parameters, p.Value)
+		case "ORGANIZER":
+			e.Organizer = value.ParseURI(p.Parameters, p.Value)
+		case "URL":
+			e.URL = value.ParseURI(p.Parameters, p.Value)
+		case "CONTACT":
+			e.Contact = value.ParseText(p.Parameters, p.Value)
+		case "ATTENDEE":
+			e.Attendee = append(e.Attendee, value.ParseURI(p.Parameters, p.Value))
+		case "COMMENT":
+			e.Comment = append(e.Comment, value.ParseText(p.Parameters, p.Value))
+		case "FREEBUSY":
+			// each period becomes a separate value
+			for _, s := range strings.Split(p.Value, ",") {
+				var v value.PeriodValue
+				v, err = zones.ParsePeriod(p.Parameters, s)
+				if err != nil {
+					break
+				}
+				e.FreeBusy = append(e.FreeBusy, v)
+			}
+		default:
+			e.Extensions = append(e.Extensions, Registry.parseExtension(p))
+		}
+
+		if err != nil {
+			return nil, p.wrap(err)
+		}
+	}
+
+	return e, nil
+}
+
+// decodeAlarm returns the alarm described by its ACTION property. Alarms with other
+// actions, or with properties that the alarm type cannot hold, are returned as
+// generic Components so that nothing is lost.
+func decodeAlarm(raw *rawComponent, zones value.TimeZones) (VAlarm, error) {
+	action := ""
+	for _, p := range raw.Properties {
+		if p.Name == "ACTION" {
+			action = strings.ToUpper(p.Value)
+		}
+	}
+
+	var description, summary value.TextValue
+	var trigger value.Trigger
+	var duration value.DurationValue
+	var repeat value.IntegerValue
+	var attendee []value.URIValue
+	var attach []value.Attachable
+	var extensions []Extension
+
+	for _, p := range raw.Properties {
+		var err error
+		switch p.Name {
+		case "DESCRIPTION":
+			description = value.ParseText(p.Parameters, p.Value)
+		case "SUMMARY":
+			summary = value.ParseText(p.Parameters, p.Value)
+		case "TRIGGER":
+			trigger, err = zones.ParseTrigger(p.Parameters, p.Value)
+		case "DURATION":
+			duration, err = value.ParseDuration(p.Parameters, p.Value)
+		case "REPEAT":
+			repeat, err = value.ParseInteger(p.Parameters, p.Value)
+		case "ATTENDEE":
+			attendee = append(attendee, value.ParseURI(p.Parameters, p.Value))
+		case "ATTACH":
+			var v value.Attachable
+			v, err = value.ParseAttachable(p.Parameters, p.Value)
+			attach = append(attach, v)
+		case "ACTION":
+			// already used
+		default:
+			extensions = append(extensions, Registry.parseExtension(p))
+		}
+
+		if err != nil {
+			return nil, p.wrap(err)
+		}
+	}
+
+	// alarms that have properties their type does not hold are kept whole
+	switch action {
+	case "AUDIO":
+		if ics.IsDefined(description) || ics.IsDefined(summary) || len(attendee) > 0 || len(attach) > 1 {
+			return componentOf(raw), nil
+		}
+		a := &VAudioAlarm{Trigger: trigger, Duration: duration, Repeat: repeat, Extensions: extensions}
+		if len(attach) > 0 {
+			a.Attach = attach[0]
+		}
+		return a, nil
+
+	case "DISPLAY":
+		if ics.IsDefined(summary) || len(attendee) > 0 || len(attach) > 0 {
+			return componentOf(raw), nil
+		}
+		return &VDisplayAlarm{Description: description, Trigger: trigger, Duration: duration, Repeat: repeat,
+			Extensions: extensions}, nil
+
+	case "EMAIL":
+		return &VEmailAlarm{Description: description, Trigger: trigger, Summary: summary,
+			Attendee: attendee, Duration: duration, Repeat: repeat, Attach: attach, Extensions: extensions}, nil
+	}
+
+	return componentOf(raw), nil
+}
+
+func (p rawProperty) wrap(err error) error {
+	return fmt.Errorf("line %d: %s: %w", p.line, p.Name, err)
+}
@@ -0,0 +1,279 @@
+package ical2_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"testing"
+)
+
+const sampleCalendar = `BEGIN:VCALENDAR
+PRODID:-//My App//Event Calendar//EN
+VERSION:2.0
+CALSCALE:GREGORIAN
+METHOD:PUBLISH
+NAME:name
+REFRESH-INTERVAL;VALUE=DURATION:PT12H
+X-WR-CALNAME:name
+BEGIN:VEVENT
+DTSTART;VALUE=DATE-TIME:20140101T080000Z
+DTEND;VALUE=DATE-TIME:20140101T130000Z
+DTSTAMP:20140101T070000Z
+UID:123
+URL;VALUE=URI:http://example.com/a/b/123
+ORGANIZER;CN=H.Tudwr:mailto:ht@throne.com
+ATTENDEE;ROLE=REQ-PARTICIPANT;CN="Blin, Ann":mailto:ann.blin@example.com
+SUMMARY:summary\, with punctuation
+DESCRIPTION:Lorem ipsum dolor sit amet\, consectetµr adipiscing elit\, sed
+  do eiusmod tempor.
+GEO;VALUE=FLOAT:37.386013;-122.082932
+RRULE;VALUE=RECUR:FREQ=WEEKLY;BYDAY=MO,WE,-1FR
+CATEGORIES:APPOINTMENT,EDUCATION
+SEQUENCE;VALUE=INTEGER:2
+TRANSP:OPAQUE
+X-MICROSOFT-CDO-BUSYSTATUS:BUSY
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Wakey wakey
+TRIGGER;VALUE=DURATION:-PT10M
+X-WR-ALARMUID:A1
+END:VALARM
+END:VEVENT
+BEGIN:VFREEBUSY
+DTSTAMP:19970901T120000Z
+UID:19970901T115957Z-76A912@example.com
+FREEBUSY;VALUE=PERIOD;FBTYPE=BUSY:19980314T233000Z/PT1H
+X-CUSTOM;X-P=1:abc
+END:VFREEBUSY
+END:VCALENDAR
+`
+
+func TestDecodeRoundTrip(t *testing.T) {
+	c, err := ical2.Decode(strings.NewReader(unixToDOSLineEndings(sampleCalendar)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.VComponent) != 2 {
+		t.Fatalf("got %d components", len(c.VComponent))
+	}
+
+	e := c.VComponent[0].(*ical2.VEvent)
+	if e.Summary.Value != "summary, with punctuation" {
+		t.Errorf("got %q", e.Summary.Value)
+	}
+	if len(e.Alarm) != 1 {
+		t.Errorf("got %d alarms", len(e.Alarm))
+	}
+	if len(e.Extensions) != 1 || e.Extensions[0].Key != "X-MICROSOFT-CDO-BUSYSTATUS" {
+		t.Errorf("got %v", e.Extensions)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := c.EncodePlain(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := buf.String(); s != sampleCalendar {
+		t.Errorf("got %s", s)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	cases := []struct {
+		input, exp string
+	}{
+		{"", "no VCALENDAR found"},
+		{"BEGIN:VEVENT\nEND:VEVENT\n", "expected VCALENDAR but got VEVENT"},
+		{"BEGIN:VCALENDAR\n", "missing END:VCALENDAR"},
+		{"BEGIN:VCALENDAR\nEND:VEVENT\n", "line 2: unexpected END:VEVENT"},
+		{"SUMMARY:x\n", "line 1: SUMMARY is outside any component"},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSEQUENCE:x\nEND:VEVENT\nEND:VCALENDAR\n", "line 3: SEQUENCE: "},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDURATION:1 hour\nEND:VEVENT\nEND:VCALENDAR\n", `line 3: DURATION: "1 hour" is not a valid duration`},
+	}
+
+	for i, c := range cases {
+		_, err := ical2.Decode(strings.NewReader(c.input))
+		if err == nil || !strings.HasPrefix(err.Error(), c.exp) {
+			t.Errorf("%d: expected %q but got %v", i, c.exp, err)
+		}
+	}
+}
+
+const outlookTimeZones = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+BEGIN:VTIMEZONE
+TZID:Eastern Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T020000
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T020000
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+RRULE:FREQ=YEARLY;BYDAY=2SU;BYMONTH=3
+END:DAYLIGHT
+END:VTIMEZONE
+BEGIN:VTIMEZONE
+TZID:India Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T000000
+TZOFFSETFROM:+0530
+TZOFFSETTO:+0530
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+DTSTART;TZID=Eastern Standard Time:20240110T090000
+DTEND;TZID=Eastern Standard Time:20240710T090000
+DTSTAMP:20240101T000000Z
+UID:1
+RECURRENCE-ID;TZID=India Standard Time:20240110T090000
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestDecodeTimeZones(t *testing.T) {
+	c, err := ical2.Decode(strings.NewReader(outlookTimeZones))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := c.VComponent[2].(*ical2.VEvent)
+	cases := []struct {
+		dt  value.DateTimeValue
+		exp string
+	}{
+		{e.Start, "2024-01-10 14:00:00 +0000 UTC"},
+		{e.End, "2024-07-10 13:00:00 +0000 UTC"},
+		{e.RecurrenceId, "2024-01-10 03:30:00 +0000 UTC"},
+	}
+
+	for i, c := range cases {
+		if s := c.dt.Value.UTC().String(); s != c.exp {
+			t.Errorf("%d: expected %s, got %s", i, c.exp, s)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := c.EncodePlain(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "DTSTART;TZID=Eastern Standard Time:20240110T090000\n") {
+		t.Errorf("got %s", buf.String())
+	}
+}
+
+func TestDecodeUnknownTimeZone(t *testing.T) {
+	input := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;TZID=Nowhere/Special:20240110T090000\nEND:VEVENT\nEND:VCALENDAR\n"
+
+	_, err := ical2.Decode(strings.NewReader(input))
+	if !errors.Is(err, value.ErrUnknownTimeZone) || !strings.HasPrefix(err.Error(), "line 3: DTSTART: ") {
+		t.Errorf("got %v", err)
+	}
+}
+
+const nestedComponents = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+METHOD:PUBLISH
+BEGIN:VEVENT
+DTSTAMP:20240101T000000Z
+UID:1
+BEGIN:VALARM
+ACTION:PROCEDURE
+TRIGGER:-PT5M
+ATTACH:ftp://example.com/reminder.exe
+END:VALARM
+BEGIN:VLOCATION
+UID:loc-1
+NAME:Room 1
+END:VLOCATION
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestDecodeKeepsNestedComponents(t *testing.T) {
+	c, err := ical2.Decode(strings.NewReader(nestedComponents))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := c.VComponent[0].(*ical2.VEvent)
+	if len(e.Alarm) != 1 || len(e.Components) != 1 {
+		t.Fatalf("got %+v", e)
+	}
+	if a, ok := e.Alarm[0].(*ical2.Component); !ok || a.Name != "VALARM" {
+		t.Errorf("got %+v", e.Alarm[0])
+	}
+
+	buf := &bytes.Buffer{}
+	if err := c.EncodePlain(buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != nestedComponents {
+		t.Errorf("got %s", s)
+	}
+}
+
+const alarmsWithExtraProperties = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+METHOD:PUBLISH
+BEGIN:VEVENT
+DTSTAMP:20240101T000000Z
+UID:1
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Reminder
+TRIGGER;VALUE=DURATION:-PT5M
+END:VALARM
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Reminder
+SUMMARY:Meeting soon
+ATTENDEE:mailto:a@example.com
+TRIGGER:-PT5M
+END:VALARM
+BEGIN:VALARM
+ACTION:AUDIO
+DESCRIPTION:Chime
+TRIGGER:-PT1M
+ATTACH:ftp://example.com/a.wav
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestDecodeKeepsAlarmProperties(t *testing.T) {
+	c, err := ical2.Decode(strings.NewReader(alarmsWithExtraProperties))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := c.VComponent[0].(*ical2.VEvent)
+	if len(e.Alarm) != 3 {
+		t.Fatalf("got %+v", e)
+	}
+	if _, ok := e.Alarm[0].(*ical2.VDisplayAlarm); !ok {
+		t.Errorf("got %+v", e.Alarm[0])
+	}
+	for _, a := range e.Alarm[1:] {
+		if _, ok := a.(*ical2.Component); !ok {
+			t.Errorf("got %+v", a)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := c.EncodePlain(buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != alarmsWithExtraProperties {
+		t.Errorf("got %s", s)
+	}
+}
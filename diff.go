@@ -0,0 +1,467 @@
+package ical2
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/value"
+	"strconv"
+	"strings"
+)
+
+// DiffKind describes how a component differs between two calendars.
+type DiffKind int
+
+const (
+	// ComponentAdded means the component is only in the new calendar.
+	ComponentAdded DiffKind = iota + 1
+	// ComponentRemoved means the component is only in the old calendar.
+	ComponentRemoved
+	// ComponentModified means the component is in both calendars but its properties differ.
+	ComponentModified
+)
+
+// String returns "added", "removed" or "modified".
+func (k DiffKind) String() string {
+	switch k {
+	case ComponentAdded:
+		return "added"
+	case ComponentRemoved:
+		return "removed"
+	case ComponentModified:
+		return "modified"
+	}
+	return "DiffKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// ComponentDiff describes one component that differs between two calendars.
+// Components are matched by their UID and RECURRENCE-ID.
+type ComponentDiff struct {
+	Kind DiffKind
+
+	// Name is the component name, e.g. "VEVENT".
+	Name string
+
+	// UID identifies the component.
+	UID string
+
+	// RecurrenceId is the RECURRENCE-ID value as written in the iCalendar text;
+	// it is blank except for recurrence instances.
+	RecurrenceId string
+
+	// Old and New are the components being compared; one of them is nil
+	// for added and removed components.
+	Old, New VComponent
+
+	// OldSequence and NewSequence are the SEQUENCE values, zero when absent.
+	OldSequence, NewSequence int
+
+	// Changes lists the property-level differences in modified components.
+	Changes []PropertyChange
+
+	// SequenceNotBumped is true if a significant property changed but the
+	// SEQUENCE was not incremented, as required by RFC-5546 section 2.1.4.
+	SequenceNotBumped bool
+}
+
+// String summarises the difference, e.g. "VEVENT 123 modified".
+func (d ComponentDiff) String() string {
+	id := d.UID
+	if d.RecurrenceId != "" {
+		id += " " + d.RecurrenceId
+	}
+	return fmt.Sprintf("%s %s %s", d.Name, id, d.Kind)
+}
+
+// PropertyChange describes a difference between the properties of two versions
+// of a component. Nested components, such as alarms, are also reported as
+// changes; for these, Name is the component name and Old and New hold the
+// content of the component.
+type PropertyChange struct {
+	// Name is the property name, e.g. "SUMMARY".
+	Name string
+
+	// Key identifies the property when there can be several with the same name;
+	// it is the property value, e.g. the address of an ATTENDEE.
+	Key string
+
+	// Parameter is the name of the parameter that changed, if the property
+	// value itself did not change.
+	Parameter string
+
+	// Old and New are the values before and after the change. Old is blank
+	// when something was added and New is blank when something was removed.
+	Old, New string
+}
+
+// String describes the change, e.g. "SUMMARY changed" or
+// "ATTENDEE mailto:x@y PARTSTAT NEEDS-ACTION→ACCEPTED".
+func (pc PropertyChange) String() string {
+	name := pc.Name
+	if pc.Key != "" {
+		name += " " + pc.Key
+	}
+
+	switch {
+	case pc.Parameter != "":
+		return fmt.Sprintf("%s %s %s→%s", name, pc.Parameter, pc.Old, pc.New)
+	case pc.Old == "":
+		return name + " added"
+	case pc.New == "":
+		return name + " removed"
+	}
+	return name + " changed"
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// significantProperties are those that require the SEQUENCE to be incremented
+// when they change (RFC-5546 section 2.1.4).
+var significantProperties = map[string]bool{
+	"DTSTART":  true,
+	"DTEND":    true,
+	"DURATION": true,
+	"DUE":      true,
+	"RRULE":    true,
+	"RDATE":    true,
+	"EXDATE":   true,
+	"STATUS":   true,
+}
+
+// ignoredProperties are bookkeeping properties that are expected to change
+// whenever a calendar is republished, so they are not compared.
+var ignoredProperties = map[string]bool{
+	"DTSTAMP":       true,
+	"LAST-MODIFIED": true,
+}
+
+// multiValuedProperties may occur more than once in a component. Their
+// instances are matched by value.
+var multiValuedProperties = map[string]bool{
+	"ATTACH":     true,
+	"ATTENDEE":   true,
+	"CATEGORIES": true,
+	"COMMENT":    true,
+	"CONFERENCE": true,
+	"CONTACT":    true,
+	"EXDATE":     true,
+	"FREEBUSY":   true,
+	"IMAGE":      true,
+	"RDATE":      true,
+	"RELATED-TO": true,
+	"RESOURCES":  true,
+}
+
+// Diff compares two versions of a calendar and reports the components that were
+// added, removed or modified. Components are matched by UID and RECURRENCE-ID.
+// The result lists the removed and modified components in the order they appear
+// in the old calendar, followed by the added components in the order they appear
+// in the new calendar.
+//
+// An error is returned if any component cannot be encoded.
+func Diff(old, new *VCalendar) ([]ComponentDiff, error) {
+	oldItems, err := diffItems(old)
+	if err != nil {
+		return nil, err
+	}
+
+	newItems, err := diffItems(new)
+	if err != nil {
+		return nil, err
+	}
+
+	newIndex := make(map[string]*diffItem)
+	for _, it := range newItems {
+		newIndex[it.key] = it
+	}
+
+	var diffs []ComponentDiff
+	matched := make(map[string]bool)
+
+	for _, o := range oldItems {
+		n, exists := newIndex[o.key]
+		if !exists {
+			diffs = append(diffs, o.diff(ComponentRemoved))
+			continue
+		}
+
+		matched[o.key] = true
+		changes := diffComponents(o.raw, n.raw)
+		if len(changes) == 0 {
+			continue
+		}
+
+		d := n.diff(ComponentModified)
+		d.Old = o.component
+		d.OldSequence = o.sequence
+		d.Changes = changes
+		for _, c := range changes {
+			if significantProperties[c.Name] && n.sequence <= o.sequence {
+				d.SequenceNotBumped = true
+			}
+		}
+		diffs = append(diffs, d)
+	}
+
+	for _, n := range newItems {
+		if !matched[n.key] {
+			diffs = append(diffs, n.diff(ComponentAdded))
+		}
+	}
+
+	return diffs, nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type diffItem struct {
+	key          string
+	uid          string
+	recurrenceId string
+	sequence     int
+	component    VComponent
+	raw          *rawComponent
+}
+
+func (it *diffItem) diff(kind DiffKind) ComponentDiff {
+	d := ComponentDiff{Kind: kind, Name: it.raw.Name, UID: it.uid, RecurrenceId: it.recurrenceId}
+	if kind == ComponentRemoved {
+		d.Old = it.component
+		d.OldSequence = it.sequence
+	} else {
+		d.New = it.component
+		d.NewSequence = it.sequence
+	}
+	return d
+}
+
+// diffItems converts the calendar's components into their raw form, keyed
+// by name, UID and RECURRENCE-ID.
+func diffItems(c *VCalendar) ([]*diffItem, error) {
+	var items []*diffItem
+	seen := make(map[string]int)
+
+	for _, component := range c.VComponent {
+		raw, err := rawOf(component, c.Method)
+		if err != nil {
+			return nil, err
+		}
+
+		it := &diffItem{component: component, raw: raw}
+		for _, p := range raw.Properties {
+			switch p.Name {
+			case "UID":
+				it.uid = p.Value
+			case "RECURRENCE-ID":
+				it.recurrenceId = p.Value
+			case "SEQUENCE":
+				it.sequence, _ = strconv.Atoi(p.Value)
+			}
+		}
+
+		it.key = raw.Name + "\x00" + it.uid + "\x00" + it.recurrenceId
+		if n := seen[it.key]; n > 0 {
+			// duplicates are matched in order
+			it.key += "\x00" + strconv.Itoa(n)
+		}
+		seen[it.key]++
+
+		items = append(items, it)
+	}
+
+	return items, nil
+}
+
+// rawOf encodes a component and reads it back in its raw form.
+func rawOf(component VComponent, method value.TextValue) (*rawComponent, error) {
+	buf := &bytes.Buffer{}
+	b := ics.NewBuffer(buf, "\n")
+	if err := component.EncodeIcal(b, method); err != nil {
+		return nil, err
+	}
+	if err := b.Flush(); err != nil {
+		return nil, err
+	}
+
+	top, err := readComponents(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(top) != 1 {
+		return nil, fmt.Errorf("expected one component but got %d", len(top))
+	}
+	return top[0], nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// diffComponents lists the property-level changes between two raw components.
+func diffComponents(old, new *rawComponent) []PropertyChange {
+	var changes []PropertyChange
+
+	oldProps := groupProperties(old.Properties)
+	newProps := groupProperties(new.Properties)
+
+	for _, name := range propertyNames(old.Properties, new.Properties) {
+		if ignoredProperties[name] {
+			continue
+		}
+
+		op, np := oldProps[name], newProps[name]
+		if multiValuedProperties[name] || len(op) > 1 || len(np) > 1 {
+			changes = append(changes, diffMultiValued(name, op, np)...)
+		} else {
+			changes = append(changes, diffSingle(name, op, np)...)
+		}
+	}
+
+	return append(changes, diffSubComponents(old.Components, new.Components)...)
+}
+
+func diffSingle(name string, op, np []rawProperty) []PropertyChange {
+	switch {
+	case len(op) == 0:
+		return []PropertyChange{{Name: name, New: np[0].Value}}
+	case len(np) == 0:
+		return []PropertyChange{{Name: name, Old: op[0].Value}}
+	case op[0].Value != np[0].Value:
+		return []PropertyChange{{Name: name, Old: op[0].Value, New: np[0].Value}}
+	}
+	return diffParameters(name, "", op[0].Parameters, np[0].Parameters)
+}
+
+func diffMultiValued(name string, op, np []rawProperty) []PropertyChange {
+	var changes []PropertyChange
+	used := make([]bool, len(np))
+
+	for _, o := range op {
+		found := false
+		for i, n := range np {
+			if !used[i] && o.Value == n.Value {
+				used[i] = true
+				found = true
+				changes = append(changes, diffParameters(name, o.Value, o.Parameters, n.Parameters)...)
+				break
+			}
+		}
+
+		if !found {
+			changes = append(changes, PropertyChange{Name: name, Key: o.Value, Old: o.Value})
+		}
+	}
+
+	for i, n := range np {
+		if !used[i] {
+			changes = append(changes, PropertyChange{Name: name, Key: n.Value, New: n.Value})
+		}
+	}
+
+	return changes
+}
+
+func diffParameters(name, key string, op, np parameter.Parameters) []PropertyChange {
+	var changes []PropertyChange
+
+	for _, o := range op {
+		n, exists := np.Get(o.Key)
+		if !exists {
+			changes = append(changes, PropertyChange{Name: name, Key: key, Parameter: o.Key, Old: paramValue(o)})
+		} else if !o.Equals(n) {
+			changes = append(changes, PropertyChange{Name: name, Key: key, Parameter: o.Key, Old: paramValue(o), New: paramValue(n)})
+		}
+	}
+
+	for _, n := range np {
+		if _, exists := op.Get(n.Key); !exists {
+			changes = append(changes, PropertyChange{Name: name, Key: key, Parameter: n.Key, New: paramValue(n)})
+		}
+	}
+
+	return changes
+}
+
+// diffSubComponents compares nested components, such as alarms, by their content.
+func diffSubComponents(old, new []*rawComponent) []PropertyChange {
+	var changes []PropertyChange
+
+	newText := make([]string, len(new))
+	for i, n := range new {
+		newText[i] = n.String()
+	}
+	used := make([]bool, len(new))
+
+	for _, o := range old {
+		text := o.String()
+		found := false
+		for i := range new {
+			if !used[i] && newText[i] == text {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			changes = append(changes, PropertyChange{Name: o.Name, Old: text})
+		}
+	}
+
+	for i, n := range new {
+		if !used[i] {
+			changes = append(changes, PropertyChange{Name: n.Name, New: newText[i]})
+		}
+	}
+
+	return changes
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// String renders the raw component as unfolded content lines.
+func (c *rawComponent) String() string {
+	buf := &strings.Builder{}
+	c.writeTo(buf)
+	return buf.String()
+}
+
+func (c *rawComponent) writeTo(buf *strings.Builder) {
+	buf.WriteString("BEGIN:" + c.Name + "\n")
+	for _, p := range c.Properties {
+		buf.WriteString(p.Name)
+		p.Parameters.WriteTo(buf)
+		buf.WriteString(":" + p.Value + "\n")
+	}
+	for _, sub := range c.Components {
+		sub.writeTo(buf)
+	}
+	buf.WriteString("END:" + c.Name + "\n")
+}
+
+func groupProperties(pp []rawProperty) map[string][]rawProperty {
+	m := make(map[string][]rawProperty)
+	for _, p := range pp {
+		m[p.Name] = append(m[p.Name], p)
+	}
+	return m
+}
+
+// propertyNames lists the distinct names in both property lists, in order of appearance.
+func propertyNames(a, b []rawProperty) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, pp := range [][]rawProperty{a, b} {
+		for _, p := range pp {
+			if !seen[p.Name] {
+				seen[p.Name] = true
+				names = append(names, p.Name)
+			}
+		}
+	}
+	return names
+}
+
+func paramValue(p parameter.Parameter) string {
+	return strings.Join(append([]string{p.Value}, p.Others...), ",")
+}
@@ -0,0 +1,163 @@
+package ical2_test
+
+import (
+	"fmt"
+	"github.com/rickb777/ical2"
+	"strings"
+	"testing"
+)
+
+const diffOld = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+BEGIN:VEVENT
+DTSTART:20240102T100000Z
+DTSTAMP:20240101T000000Z
+UID:a
+ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:x@y
+ATTENDEE:mailto:gone@y
+SUMMARY:Planning
+SEQUENCE:1
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20240103T100000Z
+DTSTAMP:20240101T000000Z
+UID:b
+SUMMARY:Review
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20240109T100000Z
+DTSTAMP:20240101T000000Z
+UID:b
+RECURRENCE-ID:20240110T100000Z
+SUMMARY:Review
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20240104T100000Z
+DTSTAMP:20240101T000000Z
+UID:c
+SUMMARY:Retro
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Soon
+TRIGGER:-PT10M
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`
+
+const diffNew = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+BEGIN:VEVENT
+DTSTART:20240102T100000Z
+DTSTAMP:20240201T000000Z
+UID:a
+ATTENDEE;PARTSTAT=ACCEPTED:mailto:x@y
+ATTENDEE:mailto:new@y
+SUMMARY:Planning meeting
+SEQUENCE:1
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20240103T110000Z
+DTSTAMP:20240201T000000Z
+UID:b
+SUMMARY:Review
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20240104T100000Z
+DTSTAMP:20240101T000000Z
+UID:c
+SUMMARY:Retro
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Soon
+TRIGGER:-PT10M
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20240105T100000Z
+DTSTAMP:20240201T000000Z
+UID:d
+SUMMARY:Social
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestDiff(t *testing.T) {
+	old, err := ical2.Decode(strings.NewReader(diffOld))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	new, err := ical2.Decode(strings.NewReader(diffNew))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := ical2.Diff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, d := range diffs {
+		lines = append(lines, d.String())
+		for _, c := range d.Changes {
+			lines = append(lines, "  "+c.String())
+		}
+		if d.SequenceNotBumped {
+			lines = append(lines, "  SEQUENCE not bumped")
+		}
+	}
+
+	exp := []string{
+		"VEVENT a modified",
+		"  ATTENDEE mailto:x@y PARTSTAT NEEDS-ACTION→ACCEPTED",
+		"  ATTENDEE mailto:gone@y removed",
+		"  ATTENDEE mailto:new@y added",
+		"  SUMMARY changed",
+		"VEVENT b modified",
+		"  DTSTART changed",
+		"  SEQUENCE not bumped",
+		"VEVENT b 20240110T100000Z removed",
+		"VEVENT d added",
+	}
+
+	if strings.Join(lines, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("expected\n%s\nbut got\n%s", strings.Join(exp, "\n"), strings.Join(lines, "\n"))
+	}
+
+	if diffs[1].OldSequence != 0 || diffs[1].Old == nil || diffs[1].New == nil {
+		t.Errorf("got %+v", diffs[1])
+	}
+}
+
+func TestDiffSubComponents(t *testing.T) {
+	old, _ := ical2.Decode(strings.NewReader(diffOld))
+	new, _ := ical2.Decode(strings.NewReader(strings.Replace(diffOld, "TRIGGER:-PT10M", "TRIGGER:-PT15M", 1)))
+
+	diffs, err := ical2.Diff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diffs) != 1 || len(diffs[0].Changes) != 2 {
+		t.Fatalf("got %v", diffs)
+	}
+
+	got := fmt.Sprintf("%s; %s", diffs[0].Changes[0], diffs[0].Changes[1])
+	if got != "VALARM removed; VALARM added" || diffs[0].SequenceNotBumped {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	old, _ := ical2.Decode(strings.NewReader(diffOld))
+	new, _ := ical2.Decode(strings.NewReader(diffOld))
+
+	diffs, err := ical2.Diff(old, new)
+	if err != nil || len(diffs) != 0 {
+		t.Errorf("got %v %v", diffs, err)
+	}
+}
@@ -0,0 +1,221 @@
+package ical2
+
+import (
+	"bytes"
+	"errors"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/value"
+	"io"
+	"strings"
+)
+
+// Encoder writes calendars to an output stream in iCalendar format. Each encoder
+// has its own options, so encoders with different options can be used
+// concurrently. The options should not be changed while encoding is in progress.
+//
+// A whole calendar can be written using Encode. Alternatively, large calendars can
+// be streamed using Begin, then WriteComponent for each component, then End; this
+// avoids holding all the components in memory.
+type Encoder struct {
+	// Options controls the line length, line ending and line folding, the caret
+	// encoding of parameter values and the treatment of control characters in text.
+	ics.Options
+
+	// Canonical writes the canonical form, in which components, properties and
+	// parameters are sorted and defaults are omitted; see EncodeCanonical. This
+	// cannot be used when streaming.
+	Canonical bool
+
+	// OmitDefaultValueTypes omits VALUE parameters that give the default type of
+	// their property, such as VALUE=DATE-TIME on DTSTART. This is implied by
+	// Canonical.
+	OmitDefaultValueTypes bool
+
+	w      io.Writer
+	b      *ics.Buffer // non-nil between Begin and End
+	method value.TextValue
+}
+
+// ErrNotStreaming is returned by WriteComponent and End when Begin has not been called.
+var ErrNotStreaming = errors.New("calendar stream has not begun")
+
+// NewEncoder returns an encoder that writes to w. Its zero-value options give
+// standard iCalendar formatting.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode validates the calendar and writes it to the stream. Calendars that have
+// problems with SeverityError are not written.
+func (e *Encoder) Encode(c *VCalendar) error {
+	if e.Canonical {
+		return e.encodeCanonical(c)
+	}
+
+	if err := c.Validate().Err(); err != nil {
+		return err
+	}
+
+	if err := e.Begin(c); err != nil {
+		return err
+	}
+
+	for _, component := range c.VComponent {
+		if err := e.writeComponent(component, true); err != nil {
+			e.b = nil
+			return err
+		}
+	}
+
+	return e.End()
+}
+
+func (e *Encoder) encodeCanonical(c *VCalendar) error {
+	raw, err := e.raw(c)
+	if err != nil {
+		return err
+	}
+
+	raw.canonicalise(true)
+
+	b := e.Options.NewBuffer(e.w)
+	raw.encode(b)
+	return b.Flush()
+}
+
+// raw encodes a calendar and reads it back in its raw form. Text values are written
+// using the encoder's control policy; the other options apply only when the raw
+// form is written, so that nothing is lost in between.
+func (e *Encoder) raw(c *VCalendar) (*rawComponent, error) {
+	buf := &bytes.Buffer{}
+	x := NewEncoder(buf)
+	x.LineEnding = "\n"
+	x.TextControls = e.TextControls
+	if err := x.Encode(c); err != nil {
+		return nil, err
+	}
+
+	top, err := readComponents(buf)
+	if err != nil {
+		return nil, err
+	}
+	return top[0], nil
+}
+
+// Begin starts streaming a calendar by writing its properties. Any components
+// held by props are ignored; they should be written using WriteComponent instead.
+// The properties are validated first and nothing is written if they have problems
+// with SeverityError.
+func (e *Encoder) Begin(props *VCalendar) error {
+	if e.Canonical {
+		return errors.New("canonical form cannot be streamed")
+	}
+
+	header := *props
+	header.VComponent = nil
+	if err := header.Validate().Err(); err != nil {
+		return err
+	}
+
+	b := e.Options.NewBuffer(e.w)
+
+	if !e.OmitDefaultValueTypes {
+		if err := header.encodeProperties(b); err != nil {
+			return err
+		}
+	} else {
+		raw, err := e.raw(&header)
+		if err != nil {
+			return err
+		}
+
+		raw.omitDefaultValueTypes()
+
+		b.WriteLine("BEGIN:VCALENDAR")
+		for _, p := range raw.Properties {
+			p.encode(b)
+		}
+		if err := b.Flush(); err != nil {
+			return err
+		}
+	}
+
+	e.b = b
+	e.method = props.Method
+	return nil
+}
+
+// WriteComponent writes one component of the calendar started by Begin. The
+// component validates itself and nothing is written if it has problems with
+// SeverityError. The output is flushed after each component, so memory use does
+// not grow with the number of components.
+func (e *Encoder) WriteComponent(component VComponent) error {
+	return e.writeComponent(component, false)
+}
+
+func (e *Encoder) writeComponent(component VComponent, validated bool) error {
+	if e.b == nil {
+		return ErrNotStreaming
+	}
+
+	if !e.OmitDefaultValueTypes {
+		if validated {
+			return encodeValidated(e.b, component, e.method)
+		}
+		return component.EncodeIcal(e.b, e.method)
+	}
+
+	raw, err := rawOf(component, e.method)
+	if err != nil {
+		return err
+	}
+
+	raw.omitDefaultValueTypes()
+	raw.encode(e.b)
+	return e.b.Flush()
+}
+
+// End finishes the calendar started by Begin. After this, the encoder can be
+// used again.
+func (e *Encoder) End() error {
+	if e.b == nil {
+		return ErrNotStreaming
+	}
+
+	e.b.WriteLine("END:VCALENDAR")
+	err := e.b.Flush()
+	e.b = nil
+	return err
+}
+
+// encodeValidated writes a component that has already been validated, avoiding
+// validating it again if possible.
+func encodeValidated(b *ics.Buffer, component VComponent, method value.TextValue) error {
+	if c, ok := component.(interface {
+		encodeIcal(*ics.Buffer, value.TextValue) error
+	}); ok {
+		return c.encodeIcal(b, method)
+	}
+	return component.EncodeIcal(b, method)
+}
+
+// omitDefaultValueTypes removes VALUE parameters that repeat the default type of
+// their property throughout the component tree.
+func (c *rawComponent) omitDefaultValueTypes() {
+	for i, p := range c.Properties {
+		var kept []parameter.Parameter
+		for _, param := range p.Parameters {
+			if strings.EqualFold(param.Key, "VALUE") && len(param.Others) == 0 &&
+				strings.EqualFold(param.Value, defaultValueType(p.Name)) {
+				continue
+			}
+			kept = append(kept, param)
+		}
+		c.Properties[i].Parameters = kept
+	}
+
+	for _, sub := range c.Components {
+		sub.omitDefaultValueTypes()
+	}
+}
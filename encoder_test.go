@@ -0,0 +1,318 @@
+package ical2_test
+
+import (
+	"bytes"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/parameter/partstat"
+	"github.com/rickb777/ical2/parameter/role"
+	"github.com/rickb777/ical2/value"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const encoderInput = `BEGIN:VCALENDAR
+PRODID:-//Test//EN
+VERSION:2.0
+BEGIN:VEVENT
+DTSTART;VALUE=DATE-TIME:20240102T100000Z
+DTSTAMP:20240101T000000Z
+UID:a
+DESCRIPTION:The quick brown fox jumps over the lazy dog and then runs away
+SUMMARY;VALUE=TEXT:Planning
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestEncoderOptions(t *testing.T) {
+	c, err := ical2.Decode(strings.NewReader(encoderInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		setup func(e *ical2.Encoder)
+		exp   []string
+	}{
+		{
+			setup: func(e *ical2.Encoder) { e.LineLength = 40 },
+			exp: []string{
+				"DTSTART;VALUE=DATE-TIME:20240102T100000Z\r\n",
+				"DESCRIPTION:The quick brown fox jumps ov\r\n er the lazy dog and then runs away\r\n",
+			},
+		},
+		{
+			setup: func(e *ical2.Encoder) { e.LineEnding = "\n"; e.NoFolding = true },
+			exp: []string{
+				"DESCRIPTION:The quick brown fox jumps over the lazy dog and then runs away\n",
+			},
+		},
+		{
+			setup: func(e *ical2.Encoder) { e.LineEnding = "\n"; e.OmitDefaultValueTypes = true },
+			exp: []string{
+				"BEGIN:VEVENT\nDTSTART:20240102T100000Z\nDTSTAMP:20240101T000000Z\n",
+				"SUMMARY:Planning\n",
+			},
+		},
+		{
+			setup: func(e *ical2.Encoder) { e.LineEnding = "\n"; e.Canonical = true },
+			exp: []string{
+				"BEGIN:VEVENT\nDESCRIPTION:The quick brown fox jumps over the lazy dog and then runs away\nDTSTAMP:20240101T000000Z\nDTSTART:20240102T100000Z\n",
+			},
+		},
+	}
+
+	for i, c2 := range cases {
+		buf := &bytes.Buffer{}
+		e := ical2.NewEncoder(buf)
+		c2.setup(e)
+		if err := e.Encode(c); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		for _, exp := range c2.exp {
+			if !strings.Contains(buf.String(), exp) {
+				t.Errorf("%d: expected %q in\n%s", i, exp, buf)
+			}
+		}
+	}
+}
+
+func TestEncoderConcurrent(t *testing.T) {
+	c, err := ical2.Decode(strings.NewReader(encoderInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lengths := []int{30, 50, 75, 200}
+	expected := make([]string, len(lengths))
+	for i, n := range lengths {
+		buf := &bytes.Buffer{}
+		e := ical2.NewEncoder(buf)
+		e.LineLength = n
+		if err := e.Encode(c); err != nil {
+			t.Fatal(err)
+		}
+		expected[i] = buf.String()
+	}
+
+	wg := &sync.WaitGroup{}
+	for r := 0; r < 20; r++ {
+		for i, n := range lengths {
+			wg.Add(1)
+			go func(i, n int) {
+				defer wg.Done()
+				buf := &bytes.Buffer{}
+				e := ical2.NewEncoder(buf)
+				e.LineLength = n
+				if err := e.Encode(c); err != nil || buf.String() != expected[i] {
+					t.Errorf("%d: %v\n%s", n, err, buf)
+				}
+			}(i, n)
+		}
+	}
+	wg.Wait()
+}
+
+func TestEncoderValueOptions(t *testing.T) {
+	event := streamEvent(0)
+	event.Summary = value.Text("Bell\x07")
+	event.Organizer = value.CalAddress("mailto:pob@example.com").With(parameter.CommonName(`Patrick O"Brien`))
+	c := ical2.NewVCalendar("-//Test//EN").With(event)
+
+	cases := []struct {
+		setup func(e *ical2.Encoder)
+		exp   string // blank for an error
+	}{
+		{setup: func(e *ical2.Encoder) {}},
+		{setup: func(e *ical2.Encoder) { e.TextControls = ics.StripControls; e.NoCaretEncoding = true }},
+		{
+			setup: func(e *ical2.Encoder) { e.TextControls = ics.StripControls },
+			exp:   "ORGANIZER;CN=Patrick O^'Brien:mailto:pob@example.com\r\nSUMMARY:Bell\r\n",
+		},
+		{
+			setup: func(e *ical2.Encoder) { e.TextControls = ics.StripControls; e.Canonical = true },
+			exp:   "ORGANIZER;CN=Patrick O^'Brien:mailto:pob@example.com\r\nSUMMARY:Bell\r\n",
+		},
+	}
+
+	// encoders with different options do not affect each other
+	wg := &sync.WaitGroup{}
+	for r := 0; r < 10; r++ {
+		for i, c2 := range cases {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				buf := &bytes.Buffer{}
+				e := ical2.NewEncoder(buf)
+				c2.setup(e)
+				err := e.Encode(c)
+				switch {
+				case c2.exp == "" && err == nil:
+					t.Errorf("%d: expected an error", i)
+				case c2.exp != "" && (err != nil || !strings.Contains(buf.String(), c2.exp)):
+					t.Errorf("%d: %v\n%s", i, err, buf)
+				}
+			}(i)
+		}
+	}
+	wg.Wait()
+}
+
+// countingWriter records how much has been written.
+type countingWriter struct {
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	return len(p), nil
+}
+
+func streamEvent(i int) *ical2.VEvent {
+	t := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Hour)
+	return &ical2.VEvent{
+		UID:     value.Text(strconv.Itoa(i)),
+		DTStamp: value.TStamp(t),
+		Start:   value.DateTime(t),
+		Summary: value.Text("Event " + strconv.Itoa(i)),
+	}
+}
+
+func TestEncoderStreaming(t *testing.T) {
+	props := ical2.NewVCalendar("-//Test//EN")
+
+	whole := ical2.NewVCalendar("-//Test//EN")
+	for i := 0; i < 3; i++ {
+		whole.With(streamEvent(i))
+	}
+	exp := &bytes.Buffer{}
+	if err := whole.Encode(exp); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	e := ical2.NewEncoder(buf)
+	if err := e.Begin(props); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := e.WriteComponent(streamEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != exp.String() {
+		t.Errorf("got\n%s\nwant\n%s", buf, exp)
+	}
+
+	if err := e.WriteComponent(streamEvent(0)); err != ical2.ErrNotStreaming {
+		t.Errorf("got %v", err)
+	}
+	if err := e.End(); err != ical2.ErrNotStreaming {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestEncoderStreamingFlushes(t *testing.T) {
+	w := &countingWriter{}
+	e := ical2.NewEncoder(w)
+	if err := e.Begin(ical2.NewVCalendar("-//Test//EN")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		before := w.n
+		if err := e.WriteComponent(streamEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+		if w.n == before {
+			t.Fatalf("%d: nothing was written", i)
+		}
+	}
+
+	if err := e.WriteComponent(&ical2.VEvent{}); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	if err := e.End(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func benchmarkCalendar(n int) *ical2.VCalendar {
+	c := ical2.NewVCalendar("-//Test//EN")
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		t := start.Add(time.Duration(i) * time.Hour)
+		c.With(&ical2.VEvent{
+			UID:         value.Text(strconv.Itoa(i) + "@example.com"),
+			DTStamp:     value.TStamp(start),
+			Start:       value.DateTime(t),
+			End:         value.DateTime(t.Add(time.Hour)),
+			Summary:     value.Text("Meeting " + strconv.Itoa(i)),
+			Description: value.Text("Agenda: budget, plans; and\nother business"),
+			Location:    value.Text("Room 1"),
+			Organizer:   value.CalAddress("boss@example.com").With(parameter.CommonName("The Boss")),
+			Attendee: []value.URIValue{
+				value.CalAddress("a@example.com").With(role.ReqParticipant(), partstat.Accepted()),
+				value.CalAddress("b@example.com").With(parameter.CommonName("B, Jr"), parameter.Rsvp(true)),
+			},
+			Categories:   []value.ListValue{value.List("MEETING", "BUSINESS")},
+			Sequence:     value.Integer(i % 3),
+			Transparency: value.Opaque(),
+		})
+	}
+	return c
+}
+
+func TestEncodeAllocations(t *testing.T) {
+	buf := &bytes.Buffer{}
+	for _, n := range []int{10, 1000} {
+		c := benchmarkCalendar(n)
+		allocs := testing.AllocsPerRun(5, func() {
+			buf.Reset()
+			if err := c.Encode(buf); err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		// a few for the encoder and its buffers, but none per component
+		if allocs > 10 {
+			t.Errorf("%d events: %v allocations", n, allocs)
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, n int) {
+	c := benchmarkCalendar(n)
+	buf := &bytes.Buffer{}
+	if err := c.Encode(buf); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(buf.Len()))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := c.Encode(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode10k(b *testing.B) {
+	benchmarkEncode(b, 10000)
+}
+
+func BenchmarkEncode100k(b *testing.B) {
+	benchmarkEncode(b, 100000)
+}
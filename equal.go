@@ -0,0 +1,385 @@
+package ical2
+
+import (
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/value"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dateTimeProperties have DATE-TIME or DATE values.
+var dateTimeProperties = map[string]bool{
+	"COMPLETED":     true,
+	"CREATED":       true,
+	"DTEND":         true,
+	"DTSTAMP":       true,
+	"DTSTART":       true,
+	"DUE":           true,
+	"EXDATE":        true,
+	"LAST-MODIFIED": true,
+	"RDATE":         true,
+	"RECURRENCE-ID": true,
+}
+
+// Equal tests whether two calendars have the same meaning. See Differences.
+func Equal(a, b *VCalendar) bool {
+	d, err := Differences(a, b)
+	return err == nil && len(d) == 0
+}
+
+// Differences compares two calendars by meaning, returning the paths to the parts
+// that differ. The paths have the same form as those in VPatch, for example
+// "/VCALENDAR/VEVENT[UID=123]#SUMMARY". An error is returned if either calendar
+// cannot be encoded.
+//
+// The comparison ignores the order of components, properties and parameters,
+// and parameters that state the default value. DATE-TIME values are compared as
+// instants, so the same time expressed in different zones is equal; TZID values
+// are compared without regard to case. Durations are compared by their length,
+// RRULEs by their parts in any order, and calendar addresses without regard to case.
+func Differences(a, b *VCalendar) ([]string, error) {
+	ra, err := rawCalendar(a)
+	if err != nil {
+		return nil, err
+	}
+
+	rb, err := rawCalendar(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return compareRaw("", ra, rb, true), nil
+}
+
+// EqualComponents tests whether two components have the same meaning. See
+// ComponentDifferences.
+func EqualComponents(a, b VComponent) bool {
+	d, err := ComponentDifferences(a, b)
+	return err == nil && len(d) == 0
+}
+
+// ComponentDifferences compares two components in the same way as Differences.
+// The paths start with the component name, e.g. "/VEVENT[UID=123]#SUMMARY".
+func ComponentDifferences(a, b VComponent) ([]string, error) {
+	ra, err := rawOf(a, value.TextValue{})
+	if err != nil {
+		return nil, err
+	}
+
+	rb, err := rawOf(b, value.TextValue{})
+	if err != nil {
+		return nil, err
+	}
+
+	if ra.Name != rb.Name {
+		return []string{"/" + ra.Name, "/" + rb.Name}, nil
+	}
+
+	return compareRaw("", ra, rb, false), nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// compareRaw compares two components with the same name. Top-level components
+// within them are matched by UID and RECURRENCE-ID; other nested components by content.
+func compareRaw(parent string, a, b *rawComponent, top bool) []string {
+	a.canonicalise(false)
+	b.canonicalise(false)
+
+	path := componentPath(parent, a)
+	if top {
+		path = parent + "/" + a.Name
+	}
+
+	var diffs []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			diffs = append(diffs, s)
+		}
+	}
+
+	aProps := groupProperties(a.Properties)
+	bProps := groupProperties(b.Properties)
+	for _, name := range propertyNames(a.Properties, b.Properties) {
+		ap, bp := aProps[name], bProps[name]
+		multi := repeatableProperty(name) || len(ap) > 1 || len(bp) > 1
+		for _, p := range unmatchedProperties(ap, bp) {
+			if multi {
+				add(propertyPath(path, p))
+			} else {
+				add(path + "#" + name)
+			}
+		}
+	}
+
+	if top {
+		for _, d := range compareIdentified(path, a.Components, b.Components) {
+			add(d)
+		}
+		return diffs
+	}
+
+	for _, d := range compareUnidentified(path, a.Components, b.Components) {
+		add(d)
+	}
+	return diffs
+}
+
+// unmatchedProperties returns the properties in either list that have no
+// equal counterpart in the other.
+func unmatchedProperties(ap, bp []rawProperty) []rawProperty {
+	var unmatched []rawProperty
+	used := make([]bool, len(bp))
+
+	for _, p := range ap {
+		found := false
+		for i, q := range bp {
+			if !used[i] && equalProperty(p, q) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, p)
+		}
+	}
+
+	for i, q := range bp {
+		if !used[i] {
+			unmatched = append(unmatched, q)
+		}
+	}
+
+	return unmatched
+}
+
+func compareIdentified(path string, ac, bc []*rawComponent) []string {
+	var diffs []string
+
+	index := make(map[string]*rawComponent)
+	for _, c := range bc {
+		index[identityKey(c)] = c
+	}
+
+	matched := make(map[string]bool)
+	for _, c := range ac {
+		key := identityKey(c)
+		other, exists := index[key]
+		if !exists {
+			diffs = append(diffs, componentPath(path, c))
+			continue
+		}
+		matched[key] = true
+		diffs = append(diffs, compareRaw(path, c, other, false)...)
+	}
+
+	for _, c := range bc {
+		if !matched[identityKey(c)] {
+			diffs = append(diffs, componentPath(path, c))
+		}
+	}
+
+	return diffs
+}
+
+func compareUnidentified(path string, ac, bc []*rawComponent) []string {
+	var diffs []string
+	used := make([]bool, len(bc))
+
+	for _, c := range ac {
+		found := false
+		for i, other := range bc {
+			if !used[i] && c.Name == other.Name && len(compareRaw(path, c, other, false)) == 0 {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			diffs = append(diffs, componentPath(path, c))
+		}
+	}
+
+	for i, c := range bc {
+		if !used[i] {
+			diffs = append(diffs, componentPath(path, c))
+		}
+	}
+
+	return diffs
+}
+
+// identityKey combines the name, UID and RECURRENCE-ID, the latter as an instant.
+func identityKey(c *rawComponent) string {
+	key := c.Name
+	for _, p := range c.Properties {
+		switch p.Name {
+		case "UID":
+			key += "\x00" + p.Value
+		case "RECURRENCE-ID":
+			if dt, err := value.ParseDateTime(p.Parameters, p.Value); err == nil && knownZone(p.Parameters) {
+				key += "\x01" + dt.Value.UTC().Format(time.RFC3339)
+			} else {
+				key += "\x01" + p.Value
+			}
+		}
+	}
+	return key
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// equalProperty compares two properties with the same name by meaning.
+func equalProperty(a, b rawProperty) bool {
+	name := a.Name
+
+	if dateTimeProperties[name] && !isPeriod(a) && !isPeriod(b) {
+		return equalParameters(withoutTZID(a.Parameters), withoutTZID(b.Parameters)) && equalDateTime(a, b)
+	}
+
+	if !equalParameters(a.Parameters, b.Parameters) {
+		return false
+	}
+
+	switch name {
+	case "DURATION", "REFRESH-INTERVAL", "TRIGGER":
+		da, errA := value.ParseDuration(nil, a.Value)
+		db, errB := value.ParseDuration(nil, b.Value)
+		if errA == nil && errB == nil {
+			xa, errA := da.Duration()
+			xb, errB := db.Duration()
+			if errA == nil && errB == nil {
+				return xa == xb
+			}
+		}
+	case "RRULE":
+		return equalRecurrence(a.Value, b.Value)
+	case "ATTENDEE", "ORGANIZER":
+		return strings.EqualFold(a.Value, b.Value)
+	}
+
+	return a.Value == b.Value
+}
+
+func isPeriod(p rawProperty) bool {
+	v, exists := p.Parameters.Get("VALUE")
+	return exists && strings.EqualFold(v.Value, "PERIOD")
+}
+
+func withoutTZID(pp parameter.Parameters) parameter.Parameters {
+	var result parameter.Parameters
+	for _, p := range pp {
+		if p.Key != parameter.TZID {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// equalParameters compares canonical parameter lists, ignoring the case of TZID.
+func equalParameters(a, b parameter.Parameters) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i, p := range a {
+		q := b[i]
+		if p.Key == parameter.TZID && q.Key == parameter.TZID && len(p.Others) == 0 && len(q.Others) == 0 {
+			if !strings.EqualFold(p.Value, q.Value) {
+				return false
+			}
+		} else if !p.Equals(q) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// equalDateTime compares date-time values as instants. Floating times are only
+// equal to floating times.
+func equalDateTime(a, b rawProperty) bool {
+	tzA, _ := a.Parameters.Get(parameter.TZID)
+	tzB, _ := b.Parameters.Get(parameter.TZID)
+
+	if strings.EqualFold(tzA.Value, tzB.Value) && a.Value == b.Value {
+		return true
+	}
+
+	if !knownZone(a.Parameters) || !knownZone(b.Parameters) {
+		return false
+	}
+
+	da, errA := value.ParseDateTime(a.Parameters, a.Value)
+	db, errB := value.ParseDateTime(b.Parameters, b.Value)
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	ta := append([]time.Time{da.Value}, da.Others...)
+	tb := append([]time.Time{db.Value}, db.Others...)
+	if len(ta) != len(tb) {
+		return false
+	}
+
+	for i := range ta {
+		if isFloating(ta[i]) != isFloating(tb[i]) || !ta[i].Equal(tb[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// knownZone is false if the TZID parameter names a zone that is not in the time zone database.
+func knownZone(pp parameter.Parameters) bool {
+	tz, exists := pp.Get(parameter.TZID)
+	if !exists {
+		return true
+	}
+	_, err := time.LoadLocation(tz.Value)
+	return err == nil
+}
+
+func isFloating(t time.Time) bool {
+	return t.Location().String() == ""
+}
+
+// equalRecurrence compares RRULE values part by part; the order of the parts,
+// and of the values in BYxxx lists, is not significant.
+func equalRecurrence(a, b string) bool {
+	pa, pb := recurrenceParts(a), recurrenceParts(b)
+	if len(pa) != len(pb) {
+		return false
+	}
+
+	for k, v := range pa {
+		if pb[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func recurrenceParts(s string) map[string]string {
+	parts := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		k = strings.ToUpper(k)
+		v = strings.ToUpper(v)
+		if strings.HasPrefix(k, "BY") {
+			values := strings.Split(v, ",")
+			sort.Strings(values)
+			v = strings.Join(values, ",")
+		}
+		if k == "WKST" && v == "MO" {
+			continue // the default
+		}
+		parts[k] = v
+	}
+	return parts
+}
@@ -0,0 +1,109 @@
+package ical2_test
+
+import (
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEqual(t *testing.T) {
+	a, err := ical2.Decode(strings.NewReader(canonicalA))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []string{
+		canonicalB,
+		strings.Replace(canonicalA, "DTSTART:20240102T100000Z", "DTSTART;TZID=Europe/Paris:20240102T110000", 1),
+		strings.Replace(canonicalA, "ATTENDEE;CN=X", "ATTENDEE;CN=X;CUTYPE=individual", 1),
+		strings.Replace(canonicalA, "mailto:x@y", "MAILTO:X@Y", 1),
+		strings.Replace(canonicalA, "TRIGGER:-PT5M", "TRIGGER:-PT300S", 1),
+		strings.Replace(canonicalA, "\nEND:VCALENDAR", "\nCALSCALE:GREGORIAN\nEND:VCALENDAR", 1),
+	}
+
+	for i, c := range cases {
+		b, err := ical2.Decode(strings.NewReader(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d, _ := ical2.Differences(a, b); len(d) != 0 {
+			t.Errorf("%d: got %v", i, d)
+		}
+		if !ical2.Equal(a, b) {
+			t.Errorf("%d: expected equal", i)
+		}
+	}
+}
+
+func TestEqualTZIDCase(t *testing.T) {
+	paris := strings.Replace(canonicalA, "DTSTART:20240102T100000Z", "DTSTART;TZID=Europe/Paris:20240102T110000", 1)
+	a, _ := ical2.Decode(strings.NewReader(paris))
+	b, _ := ical2.Decode(strings.NewReader(paris))
+
+	// a TZID in a different case is not in the time zone database, so cannot be decoded
+	e := b.VComponent[1].(*ical2.VEvent)
+	e.Start = e.Start.With(parameter.TZid("EUROPE/PARIS"))
+
+	if !ical2.Equal(a, b) {
+		t.Errorf("expected equal")
+	}
+}
+
+func TestDifferences(t *testing.T) {
+	a, _ := ical2.Decode(strings.NewReader(canonicalA))
+
+	cases := map[string]string{
+		strings.Replace(canonicalA, "SUMMARY:Planning", "SUMMARY:Plans", 1):                       "/VCALENDAR/VEVENT[UID=a]#SUMMARY",
+		strings.Replace(canonicalA, "DTSTART:20240102T100000Z", "DTSTART:20240102T100000", 1):     "/VCALENDAR/VEVENT[UID=a]#DTSTART",
+		strings.Replace(canonicalA, "DTSTART:20240102T100000Z", "DTSTART;VALUE=DATE:20240102", 1): "/VCALENDAR/VEVENT[UID=a]#DTSTART",
+		strings.Replace(canonicalA, "PARTSTAT=accepted", "PARTSTAT=declined", 1):                  "/VCALENDAR/VEVENT[UID=a]#ATTENDEE[=mailto:x@y]",
+		strings.Replace(canonicalA, "TRIGGER:-PT5M", "TRIGGER:-PT6M", 1):                          "/VCALENDAR/VEVENT[UID=a]/VALARM",
+		strings.Replace(canonicalA, "UID:b", "UID:c", 1):                                          "/VCALENDAR/VEVENT[UID=b] /VCALENDAR/VEVENT[UID=c]",
+		strings.Replace(canonicalA, "X-A:1", "X-A:0", 1):                                          "/VCALENDAR#X-A[=1] /VCALENDAR#X-A[=0]",
+	}
+
+	for c, exp := range cases {
+		b, err := ical2.Decode(strings.NewReader(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := ical2.Differences(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(d, " ") != exp {
+			t.Errorf("expected %s but got %v", exp, d)
+		}
+	}
+}
+
+func TestEqualComponents(t *testing.T) {
+	dt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	paris, _ := time.LoadLocation("Europe/Paris")
+
+	rr1 := value.Recurrence(value.WEEKLY)
+	rr1.ByDay = []value.WeekDayNum{value.MO, value.WE}
+	rr2 := value.Recurrence(value.WEEKLY)
+	rr2.ByDay = []value.WeekDayNum{value.WE, value.MO}
+
+	a := &ical2.VEvent{UID: value.Text("1"), DTStamp: value.TStamp(dt), Start: value.DateTime(dt), RecurrenceRule: rr1}
+	b := &ical2.VEvent{UID: value.Text("1"), DTStamp: value.TStamp(dt), Start: value.DateTime(dt.In(paris)).With(parameter.TZid("Europe/Paris")), RecurrenceRule: rr2}
+
+	if d, err := ical2.ComponentDifferences(a, b); err != nil || len(d) != 0 {
+		t.Errorf("got %v %v", d, err)
+	}
+
+	b.Summary = value.Text("x")
+	if d, _ := ical2.ComponentDifferences(a, b); strings.Join(d, " ") != "/VEVENT[UID=1]#SUMMARY" {
+		t.Errorf("got %v", d)
+	}
+
+	if ical2.EqualComponents(a, &ical2.VFreeBusy{UID: value.Text("1"), DTStamp: value.TStamp(dt)}) {
+		t.Errorf("expected different")
+	}
+}
@@ -1,7 +1,6 @@
 package ical2
 
 import (
-	"fmt"
 	"github.com/rickb777/ical2/ics"
 	"github.com/rickb777/ical2/value"
 )
@@ -171,8 +170,16 @@ type VEvent struct {
 	// https://tools.ietf.org/html/rfc7986#section-5.10
 	Image []value.Attachable
 
+	// Extensions holds any additional non-standard or unsupported properties,
+	// such as X-MICROSOFT-CDO-BUSYSTATUS.
+	Extensions []Extension
+
 	// Alarm attaches as many alarms to the event as are required.
 	Alarm []VAlarm
+
+	// Components holds any other nested components, such as VLOCATION (RFC-9073).
+	// They are written after the alarms.
+	Components []VComponent
 }
 
 // AllDay changes the start and end to represent dates without time.
@@ -183,81 +190,180 @@ func (e *VEvent) AllDay() *VEvent {
 	return e
 }
 
-// EncodeIcal serialises the event to the buffer in iCalendar ics format
-// (a VComponent method).
-func (e *VEvent) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+// Extend adds an extension property to the event.
+// The VEvent is modified and is returned.
+func (e *VEvent) Extend(key string, value ics.Valuer) *VEvent {
+	e.Extensions = append(e.Extensions, Extension{key, value})
+	return e
+}
+
+// Validate checks the event and its alarms, returning every problem found. The
+// event is checked as though its calendar has no METHOD, so Start is required.
+func (e *VEvent) Validate() Problems {
+	return e.validate("", 0, value.TextValue{})
+}
 
-	if !ics.IsDefined(e.DTStamp) {
-		return fmt.Errorf("DTstamp is required")
+func (e *VEvent) validate(parent string, index int, method value.TextValue) Problems {
+	v := &validation{parent: parent, name: "VEVENT", uid: e.UID, index: index}
+
+	v.schema("VEVENT", e.counts())
+
+	if !ics.IsDefined(method) && !ics.IsDefined(e.Start) {
+		v.add("DTSTART", MissingProperty, SeverityError, "when Method is undefined, DTSTART is required")
 	}
 
-	if !ics.IsDefined(e.UID) {
-		return fmt.Errorf("UID is required")
+	if ics.IsDefined(e.Start) && ics.IsDefined(e.End) && e.End.Value.Before(e.Start.Value) {
+		v.add("DTEND", InconsistentValues, SeverityError, "DTEND must not be before DTSTART")
 	}
 
-	if !ics.IsDefined(method) && !ics.IsDefined(e.Start) {
-		return fmt.Errorf("when Method is undefined, Start is required")
+	if ics.IsDefined(e.Priority) && (e.Priority.Value < 0 || e.Priority.Value > 9) {
+		v.add("PRIORITY", InvalidValue, SeverityError, "PRIORITY %d is out of the range 0 to 9", e.Priority.Value)
+	}
+
+	if ics.IsDefined(e.Sequence) && e.Sequence.Value < 0 {
+		v.add("SEQUENCE", InvalidValue, SeverityError, "SEQUENCE %d must not be negative", e.Sequence.Value)
+	}
+
+	v.values("DTSTART", &e.Start)
+	v.values("DTEND", &e.End)
+	v.values("RECURRENCE-ID", &e.RecurrenceId)
+	for i := range e.ExceptionDate {
+		v.values("EXDATE", &e.ExceptionDate[i])
 	}
+	for _, r := range e.RecurrenceDate {
+		v.values("RDATE", r)
+	}
+	v.values("RRULE", &e.RecurrenceRule)
+	v.values("DURATION", &e.Duration)
 
-	if ics.IsDefined(e.End) && ics.IsDefined(e.Duration) {
-		return fmt.Errorf("End and Duration are exclusive; only one can be set")
+	if len(e.Attendee) > 0 && !ics.IsDefined(e.Organizer) {
+		v.add("ORGANIZER", MissingProperty, SeverityWarning, "ORGANIZER should be set when there are attendees")
 	}
 
+	v.extensions(e.Extensions)
+
+	for i, alarm := range e.Alarm {
+		v.child(v.within(), alarm, i, method)
+	}
+
+	for i, component := range e.Components {
+		v.child(v.within(), component, i, method)
+	}
+
+	return v.problems
+}
+
+func (e *VEvent) counts() *counter {
+	c := newCounter().
+		add("DTSTART", &e.Start).
+		add("DTEND", &e.End).
+		add("DURATION", &e.Duration).
+		add("CREATED", &e.Created).
+		add("DTSTAMP", &e.DTStamp).
+		add("LAST-MODIFIED", &e.LastModified).
+		add("RRULE", &e.RecurrenceRule).
+		add("RECURRENCE-ID", &e.RecurrenceId).
+		add("ORGANIZER", &e.Organizer).
+		add("SUMMARY", &e.Summary).
+		add("DESCRIPTION", &e.Description).
+		add("CLASS", &e.Class).
+		add("RELATED-TO", &e.RelatedTo).
+		add("URL", &e.URL).
+		add("UID", &e.UID).
+		add("SEQUENCE", &e.Sequence).
+		add("PRIORITY", &e.Priority).
+		add("STATUS", &e.Status).
+		add("LOCATION", &e.Location).
+		add("GEO", &e.Geo).
+		add("TRANSP", &e.Transparency).
+		add("COLOR", &e.Color)
+	countAll(c, "EXDATE", e.ExceptionDate)
+	countEach(c, "RDATE", e.RecurrenceDate)
+	countAll(c, "CONFERENCE", e.Conference)
+	countAll(c, "ATTENDEE", e.Attendee)
+	countAll(c, "CONTACT", e.Contact)
+	countAll(c, "COMMENT", e.Comment)
+	countAll(c, "CATEGORIES", e.Categories)
+	countAll(c, "RESOURCES", e.Resources)
+	countEach(c, "ATTACH", e.Attach)
+	countEach(c, "IMAGE", e.Image)
+	return c
+}
+
+// EncodeIcal serialises the event to the buffer in iCalendar ics format
+// (a VComponent method).
+func (e *VEvent) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+	if err := e.validate("", 0, method).Err(); err != nil {
+		return err
+	}
+	return e.encodeIcal(b, method)
+}
+
+// encodeIcal writes the event without validating it first.
+func (e *VEvent) encodeIcal(b *ics.Buffer, method value.TextValue) error {
 	b.WriteLine("BEGIN:VEVENT")
 
-	b.WriteValuerLine(ics.IsDefined(e.Start), "DTSTART", e.Start)
-	b.WriteValuerLine(ics.IsDefined(e.End), "DTEND", e.End)
-	b.WriteValuerLine(true, "DTSTAMP", e.DTStamp)
-	b.WriteValuerLine(true, "UID", e.UID)
-	b.WriteValuerLine(ics.IsDefined(e.URL), "URL", e.URL)
-	b.WriteValuerLine(ics.IsDefined(e.Organizer), "ORGANIZER", e.Organizer)
-	for _, attendee := range e.Attendee {
-		b.WriteValuerLine(true, "ATTENDEE", attendee)
+	b.WriteValuerLine(ics.IsDefined(e.Start), "DTSTART", &e.Start)
+	b.WriteValuerLine(ics.IsDefined(e.End), "DTEND", &e.End)
+	b.WriteValuerLine(true, "DTSTAMP", &e.DTStamp)
+	b.WriteValuerLine(true, "UID", &e.UID)
+	b.WriteValuerLine(ics.IsDefined(e.URL), "URL", &e.URL)
+	b.WriteValuerLine(ics.IsDefined(e.Organizer), "ORGANIZER", &e.Organizer)
+	for i := range e.Attendee {
+		b.WriteValuerLine(true, "ATTENDEE", &e.Attendee[i])
 	}
-	for _, conference := range e.Conference {
-		b.WriteValuerLine(true, "CONFERENCE", conference)
+	for i := range e.Conference {
+		b.WriteValuerLine(true, "CONFERENCE", &e.Conference[i])
 	}
-	for _, contact := range e.Contact {
-		b.WriteValuerLine(true, "CONTACT", contact)
+	for i := range e.Contact {
+		b.WriteValuerLine(true, "CONTACT", &e.Contact[i])
 	}
-	b.WriteValuerLine(ics.IsDefined(e.Summary), "SUMMARY", e.Summary)
-	b.WriteValuerLine(ics.IsDefined(e.Description), "DESCRIPTION", e.Description)
-	b.WriteValuerLine(ics.IsDefined(e.Location), "LOCATION", e.Location)
-	b.WriteValuerLine(ics.IsDefined(e.Geo), "GEO", e.Geo)
-	b.WriteValuerLine(ics.IsDefined(e.Class), "CLASS", e.Class)
-	for _, comment := range e.Comment {
-		b.WriteValuerLine(ics.IsDefined(comment), "COMMENT", comment)
+	b.WriteValuerLine(ics.IsDefined(e.Summary), "SUMMARY", &e.Summary)
+	b.WriteValuerLine(ics.IsDefined(e.Description), "DESCRIPTION", &e.Description)
+	b.WriteValuerLine(ics.IsDefined(e.Location), "LOCATION", &e.Location)
+	b.WriteValuerLine(ics.IsDefined(e.Geo), "GEO", &e.Geo)
+	b.WriteValuerLine(ics.IsDefined(e.Class), "CLASS", &e.Class)
+	for i := range e.Comment {
+		b.WriteValuerLine(ics.IsDefined(&e.Comment[i]), "COMMENT", &e.Comment[i])
 	}
-	b.WriteValuerLine(ics.IsDefined(e.Created), "CREATED", e.Created)
-	b.WriteValuerLine(ics.IsDefined(e.LastModified), "LAST-MODIFIED", e.LastModified)
-	for _, date := range e.ExceptionDate {
-		b.WriteValuerLine(true, "EXDATE", date)
+	b.WriteValuerLine(ics.IsDefined(e.Created), "CREATED", &e.Created)
+	b.WriteValuerLine(ics.IsDefined(e.LastModified), "LAST-MODIFIED", &e.LastModified)
+	for i := range e.ExceptionDate {
+		b.WriteValuerLine(true, "EXDATE", &e.ExceptionDate[i])
 	}
 	for _, date := range e.RecurrenceDate {
 		b.WriteValuerLine(true, "RDATE", date)
 	}
-	b.WriteValuerLine(ics.IsDefined(e.RecurrenceRule), "RRULE", e.RecurrenceRule)
-	b.WriteValuerLine(ics.IsDefined(e.RecurrenceId), "RECURRENCE-ID", e.RecurrenceId)
-	b.WriteValuerLine(ics.IsDefined(e.RelatedTo), "RELATED-TO", e.RelatedTo)
-	for _, cat := range e.Categories {
-		b.WriteValuerLine(true, "CATEGORIES", cat)
+	b.WriteValuerLine(ics.IsDefined(e.RecurrenceRule), "RRULE", &e.RecurrenceRule)
+	b.WriteValuerLine(ics.IsDefined(e.RecurrenceId), "RECURRENCE-ID", &e.RecurrenceId)
+	b.WriteValuerLine(ics.IsDefined(e.RelatedTo), "RELATED-TO", &e.RelatedTo)
+	for i := range e.Categories {
+		b.WriteValuerLine(true, "CATEGORIES", &e.Categories[i])
 	}
-	for _, res := range e.Resources {
-		b.WriteValuerLine(true, "RESOURCES", res)
+	for i := range e.Resources {
+		b.WriteValuerLine(true, "RESOURCES", &e.Resources[i])
 	}
-	b.WriteValuerLine(ics.IsDefined(e.Sequence), "SEQUENCE", e.Sequence)
-	b.WriteValuerLine(ics.IsDefined(e.Priority), "PRIORITY", e.Priority)
-	b.WriteValuerLine(ics.IsDefined(e.Status), "STATUS", e.Status)
-	b.WriteValuerLine(ics.IsDefined(e.Transparency), "TRANSP", e.Transparency)
-	b.WriteValuerLine(ics.IsDefined(e.Color), "COLOR", e.Color)
+	b.WriteValuerLine(ics.IsDefined(e.Sequence), "SEQUENCE", &e.Sequence)
+	b.WriteValuerLine(ics.IsDefined(e.Priority), "PRIORITY", &e.Priority)
+	b.WriteValuerLine(ics.IsDefined(e.Status), "STATUS", &e.Status)
+	b.WriteValuerLine(ics.IsDefined(e.Transparency), "TRANSP", &e.Transparency)
+	b.WriteValuerLine(ics.IsDefined(e.Color), "COLOR", &e.Color)
 	for _, attachment := range e.Attach {
 		b.WriteValuerLine(true, "ATTACH", attachment)
 	}
 	for _, image := range e.Image {
 		b.WriteValuerLine(true, "IMAGE", image)
 	}
+	writeExtensions(b, e.Extensions)
 	for _, alarm := range e.Alarm {
-		alarm.EncodeIcal(b, method)
+		if err := encodeValidated(b, alarm, method); err != nil {
+			return err
+		}
+	}
+	for _, component := range e.Components {
+		if err := encodeValidated(b, component, method); err != nil {
+			return err
+		}
 	}
 
 	b.WriteLine("END:VEVENT")
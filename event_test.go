@@ -229,3 +229,45 @@ func ExampleVEvent_meeting() {
 	// END:VEVENT
 	// END:VCALENDAR
 }
+
+func ExampleVEvent_extensions() {
+	dt := time.Date(2014, time.Month(1), 1, 7, 0, 0, 0, time.UTC)
+
+	alarm := (&ical2.VDisplayAlarm{
+		Description: value.Text("Reminder"),
+		Trigger:     value.Duration("-PT15M"),
+	}).Extend("X-WR-ALARMUID", value.Text("A1"))
+
+	event := (&ical2.VEvent{
+		UID:     value.Text("123"),
+		DTStamp: value.TStamp(dt),
+		Start:   value.DateTime(dt.Add(time.Hour)),
+		Summary: value.Text("Planning"),
+		Alarm:   []ical2.VAlarm{alarm},
+	}).Extend("X-MICROSOFT-CDO-BUSYSTATUS", value.Text("BUSY")).
+		Extend("X-GOOGLE-CONFERENCE", value.URI("https://meet.google.com/abc-defg-hij"))
+
+	c := ical2.NewVCalendar("-//My App//Event Calendar//EN").With(event)
+	fmt.Println(c.String())
+
+	// Output:
+	// BEGIN:VCALENDAR
+	// PRODID:-//My App//Event Calendar//EN
+	// VERSION:2.0
+	// CALSCALE:GREGORIAN
+	// BEGIN:VEVENT
+	// DTSTART;VALUE=DATE-TIME:20140101T080000Z
+	// DTSTAMP:20140101T070000Z
+	// UID:123
+	// SUMMARY:Planning
+	// X-MICROSOFT-CDO-BUSYSTATUS:BUSY
+	// X-GOOGLE-CONFERENCE;VALUE=URI:https://meet.google.com/abc-defg-hij
+	// BEGIN:VALARM
+	// ACTION:DISPLAY
+	// DESCRIPTION:Reminder
+	// TRIGGER;VALUE=DURATION:-PT15M
+	// X-WR-ALARMUID:A1
+	// END:VALARM
+	// END:VEVENT
+	// END:VCALENDAR
+}
@@ -1,7 +1,6 @@
 package ical2
 
 import (
-	"fmt"
 	"github.com/rickb777/ical2/ics"
 	"github.com/rickb777/ical2/value"
 )
@@ -19,42 +18,90 @@ type VFreeBusy struct {
 	Comment   []value.TextValue
 	FreeBusy  []value.PeriodValue
 	//TODO []rstatus
+
+	// Extensions holds any additional non-standard or unsupported properties.
+	Extensions []Extension
 }
 
-// EncodeIcal serialises the event to the buffer in iCalendar ics format
-// (a VComponent method).
-func (e *VFreeBusy) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+// Extend adds an extension property to the free/busy component.
+// The VFreeBusy is modified and is returned.
+func (e *VFreeBusy) Extend(key string, value ics.Valuer) *VFreeBusy {
+	e.Extensions = append(e.Extensions, Extension{key, value})
+	return e
+}
 
-	if !ics.IsDefined(e.DTStamp) {
-		return fmt.Errorf("DTstamp is required")
+// Validate checks the free/busy component, returning every problem found. It is
+// checked as though its calendar has no METHOD, so Start is required.
+func (e *VFreeBusy) Validate() Problems {
+	return e.validate("", 0, value.TextValue{})
+}
+
+func (e *VFreeBusy) validate(parent string, index int, method value.TextValue) Problems {
+	v := &validation{parent: parent, name: "VFREEBUSY", uid: e.UID, index: index}
+
+	v.schema("VFREEBUSY", e.counts())
+
+	if !ics.IsDefined(method) && !ics.IsDefined(e.Start) {
+		v.add("DTSTART", MissingProperty, SeverityError, "when Method is undefined, DTSTART is required")
 	}
 
-	if !ics.IsDefined(e.UID) {
-		return fmt.Errorf("UID is required")
+	if ics.IsDefined(e.Start) && ics.IsDefined(e.End) && e.End.Value.Before(e.Start.Value) {
+		v.add("DTEND", InconsistentValues, SeverityError, "DTEND must not be before DTSTART")
 	}
 
-	if !ics.IsDefined(method) && !ics.IsDefined(e.Start) {
-		return fmt.Errorf("When Method is undefined, Start is required")
+	v.values("DTSTART", &e.Start)
+	v.values("DTEND", &e.End)
+
+	v.extensions(e.Extensions)
+
+	return v.problems
+}
+
+func (e *VFreeBusy) counts() *counter {
+	c := newCounter().
+		add("UID", &e.UID).
+		add("DTSTAMP", &e.DTStamp).
+		add("DTSTART", &e.Start).
+		add("DTEND", &e.End).
+		add("ORGANIZER", &e.Organizer).
+		add("URL", &e.URL).
+		add("CONTACT", &e.Contact)
+	countAll(c, "ATTENDEE", e.Attendee)
+	countAll(c, "COMMENT", e.Comment)
+	countAll(c, "FREEBUSY", e.FreeBusy)
+	return c
+}
+
+// EncodeIcal serialises the event to the buffer in iCalendar ics format
+// (a VComponent method).
+func (e *VFreeBusy) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+	if err := e.validate("", 0, method).Err(); err != nil {
+		return err
 	}
+	return e.encodeIcal(b, method)
+}
 
+// encodeIcal writes the free/busy component without validating it first.
+func (e *VFreeBusy) encodeIcal(b *ics.Buffer, method value.TextValue) error {
 	b.WriteLine("BEGIN:VFREEBUSY")
 
-	b.WriteValuerLine(ics.IsDefined(e.Start), "DTSTART", e.Start)
-	b.WriteValuerLine(ics.IsDefined(e.End), "DTEND", e.End)
-	b.WriteValuerLine(true, "DTSTAMP", e.DTStamp)
-	b.WriteValuerLine(true, "UID", e.UID)
-	b.WriteValuerLine(ics.IsDefined(e.Organizer), "ORGANIZER", e.Organizer)
-	for _, attendee := range e.Attendee {
-		b.WriteValuerLine(true, "ATTENDEE", attendee)
+	b.WriteValuerLine(ics.IsDefined(e.Start), "DTSTART", &e.Start)
+	b.WriteValuerLine(ics.IsDefined(e.End), "DTEND", &e.End)
+	b.WriteValuerLine(true, "DTSTAMP", &e.DTStamp)
+	b.WriteValuerLine(true, "UID", &e.UID)
+	b.WriteValuerLine(ics.IsDefined(e.Organizer), "ORGANIZER", &e.Organizer)
+	for i := range e.Attendee {
+		b.WriteValuerLine(true, "ATTENDEE", &e.Attendee[i])
 	}
-	b.WriteValuerLine(ics.IsDefined(e.Contact), "CONTACT", e.Contact)
-	b.WriteValuerLine(ics.IsDefined(e.URL), "URL", e.URL)
-	for _, comment := range e.Comment {
-		b.WriteValuerLine(ics.IsDefined(comment), "COMMENT", comment)
+	b.WriteValuerLine(ics.IsDefined(e.Contact), "CONTACT", &e.Contact)
+	b.WriteValuerLine(ics.IsDefined(e.URL), "URL", &e.URL)
+	for i := range e.Comment {
+		b.WriteValuerLine(ics.IsDefined(&e.Comment[i]), "COMMENT", &e.Comment[i])
 	}
-	for _, fb := range e.FreeBusy {
-		b.WriteValuerLine(ics.IsDefined(fb), "FREEBUSY", fb)
+	for i := range e.FreeBusy {
+		b.WriteValuerLine(ics.IsDefined(&e.FreeBusy[i]), "FREEBUSY", &e.FreeBusy[i])
 	}
+	writeExtensions(b, e.Extensions)
 
 	b.WriteLine("END:VFREEBUSY")
 
@@ -7,11 +7,11 @@ toolchain go1.24.3
 require (
 	github.com/magefile/mage v1.15.0
 	github.com/rickb777/date/v2 v2.2.3
+	github.com/rickb777/period v1.0.19
 )
 
 require (
 	github.com/govalues/decimal v0.1.36 // indirect
-	github.com/rickb777/period v1.0.19 // indirect
 	github.com/rickb777/plural v1.4.6 // indirect
 )
 
@@ -1,6 +1,6 @@
 // Package ical2 provides a data model for the iCalendar specification. Marshalling
-// to the textual iCalendar ics format is implemented. Unmarshalling is not currently
-// supported.
+// to the textual iCalendar ics format is implemented. Unmarshalling is supported for
+// the components and properties that are modelled here; see Decode.
 //
 // See
 // https://tools.ietf.org/html/rfc5545
@@ -52,11 +52,12 @@ type VCalendar struct {
 	Name            value.TextValue     // My Calendar Name
 	Description     value.TextValue     // A description of my calendar
 	URL             value.TextValue     // http://my.calendar/url
+	Source          value.URIValue      // where the calendar can be refreshed from
 	LastModified    value.DateTimeValue // can also be specified per VComponent
 	RecurrenceId    value.DateTimeValue
 	RefreshInterval value.DurationValue // PT12H
 	Color           value.TextValue     // CSS3 color name
-	// TODO CATEGORIES, SOURCE, []IMAGE
+	// TODO CATEGORIES, []IMAGE
 
 	//X_WR_CALNAME string // My Calendar Name
 	//X_WR_CALDESC string // A description of my calendar
@@ -91,50 +92,42 @@ func (c *VCalendar) With(component VComponent) *VCalendar {
 	return c
 }
 
-// doEncode encodes the calendar in ICS format, writing it to some Writer. The
-// lineEnding can be "" or "\r\n" for normal iCalendar formatting, or "\n" in other cases.
-func (c *VCalendar) doEncode(w io.Writer, lineEnding string) error {
-	b := ics.NewBuffer(w, lineEnding)
-
+// encodeProperties writes the start of the calendar and its properties to the buffer
+// in ICS format, but not its components.
+func (c *VCalendar) encodeProperties(b *ics.Buffer) error {
 	b.WriteLine("BEGIN:VCALENDAR")
 
-	b.WriteValuerLine(true, "PRODID", c.ProdId)
-	b.WriteValuerLine(true, "VERSION", c.Version)
-	b.WriteValuerLine(ics.IsDefined(c.CalScale), "CALSCALE", c.CalScale)
-	b.WriteValuerLine(ics.IsDefined(c.Method), "METHOD", c.Method)
-	b.WriteValuerLine(ics.IsDefined(c.Name), "NAME", c.Name)
-	b.WriteValuerLine(ics.IsDefined(c.Description), "DESCRIPTION", c.Description)
-	b.WriteValuerLine(ics.IsDefined(c.URL), "URL", c.URL)
-	b.WriteValuerLine(ics.IsDefined(c.LastModified), "LAST-MODIFIED", c.LastModified)
-	b.WriteValuerLine(ics.IsDefined(c.RecurrenceId), "RECURRENCE-ID", c.RecurrenceId)
-	b.WriteValuerLine(ics.IsDefined(c.Color), "COLOR", c.Color)
-	b.WriteValuerLine(ics.IsDefined(c.RefreshInterval), "REFRESH-INTERVAL", c.RefreshInterval)
-
-	for _, extension := range c.Extensions {
-		b.WriteValuerLine(true, extension.Key, extension.Value)
-	}
-
-	for _, component := range c.VComponent {
-		if err := component.EncodeIcal(b, c.Method); err != nil {
-			return err
-		}
-	}
-
-	b.WriteLine("END:VCALENDAR")
+	b.WriteValuerLine(true, "PRODID", &c.ProdId)
+	b.WriteValuerLine(true, "VERSION", &c.Version)
+	b.WriteValuerLine(ics.IsDefined(c.CalScale), "CALSCALE", &c.CalScale)
+	b.WriteValuerLine(ics.IsDefined(c.Method), "METHOD", &c.Method)
+	b.WriteValuerLine(ics.IsDefined(c.Name), "NAME", &c.Name)
+	b.WriteValuerLine(ics.IsDefined(c.Description), "DESCRIPTION", &c.Description)
+	b.WriteValuerLine(ics.IsDefined(c.URL), "URL", &c.URL)
+	b.WriteValuerLine(ics.IsDefined(c.Source), "SOURCE", &c.Source)
+	b.WriteValuerLine(ics.IsDefined(c.LastModified), "LAST-MODIFIED", &c.LastModified)
+	b.WriteValuerLine(ics.IsDefined(c.RecurrenceId), "RECURRENCE-ID", &c.RecurrenceId)
+	b.WriteValuerLine(ics.IsDefined(c.Color), "COLOR", &c.Color)
+	b.WriteValuerLine(ics.IsDefined(c.RefreshInterval), "REFRESH-INTERVAL", &c.RefreshInterval)
+
+	writeExtensions(b, c.Extensions)
 
 	return b.Flush()
 }
 
 // Encode encodes the calendar in ICS format, writing it to some Writer. The
 // line endings are "\r\n" for normal iCalendar transmission purposes.
+// Use an Encoder for other options.
 func (c *VCalendar) Encode(w io.Writer) error {
-	return c.doEncode(w, "\r\n")
+	return NewEncoder(w).Encode(c)
 }
 
 // EncodePlain encodes the calendar in ICS format, writing it to some Writer. The
 // line ending are "\n" for non-transmission purposes, e.g. for viewing.
 func (c *VCalendar) EncodePlain(w io.Writer) error {
-	return c.doEncode(w, "\n")
+	e := NewEncoder(w)
+	e.LineEnding = "\n"
+	return e.Encode(c)
 }
 
 // String returns the ICS formatted content, albeit using "\n" line endings.
@@ -153,8 +146,14 @@ type VComponent interface {
 }
 
 // Extension is a key/value struct for any additional non-standard or unsupported
-// calendar properties.
+// properties of a calendar or a component.
 type Extension struct {
 	Key   string
 	Value ics.Valuer
 }
+
+func writeExtensions(b *ics.Buffer, extensions []Extension) {
+	for _, extension := range extensions {
+		b.WriteValuerLine(true, extension.Key, extension.Value)
+	}
+}
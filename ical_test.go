@@ -55,12 +55,12 @@ ATTENDEE;ROLE=REQ-PARTICIPANT;CN=Ann Blin:mailto:ann.blin@example.com
 CONTACT:T.Moore\, Esq.
 SUMMARY:summary\, with punctuation
 DESCRIPTION:Lorem ipsum dolor sit amet\, consectetµr adipiscing elit\, sed
-  do eiusmod tempor incididµnt µt labore et dolore magna aliqua. Ut enim a
- d minim veniam\, quis nostrud exercitation ullamco laboris nisi ut aliquip 
- ex ea commodo consequat. Duis aute irure dolor in reprehenderit in voluptat
- e velit esse cillum dolore eu fugiat nulla pariatur. Excepteur sint occaeca
- t cupidatat non proident\, sunt in culpa qui officia deserunt mollit anim i
- d est laborum.
+  do eiusmod tempor incididµnt µt labore et dolore magna aliqua. Ut enim 
+ ad minim veniam\, quis nostrud exercitation ullamco laboris nisi ut aliqui
+ p ex ea commodo consequat. Duis aute irure dolor in reprehenderit in volup
+ tate velit esse cillum dolore eu fugiat nulla pariatur. Excepteur sint occ
+ aecat cupidatat non proident\, sunt in culpa qui officia deserunt mollit a
+ nim id est laborum.
 LOCATION:South Bank\, London SE1 9PX
 RELATED-TO:19960401-080045-4000F192713-0052@example.com
 TRANSP:TRANSPARENT
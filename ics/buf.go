@@ -4,7 +4,12 @@ package ics
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Valuer holds an iCalendar property value.
@@ -32,50 +37,132 @@ type Flusher interface {
 	Flush() error
 }
 
+// ErrUnsafe is returned by writers when a value contains a character that would
+// corrupt the content line, for example a line break that could inject a property.
+var ErrUnsafe = errors.New("unsafe character")
+
+// ErrInvalidUTF8 is returned by writers when a text value is not valid UTF-8.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8")
+
+// CheckControls returns an error wrapping ErrUnsafe if s contains a control
+// character (including DEL) that is not in allowed.
+func CheckControls(s, allowed string) error {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < ' ' || c == 0x7f) && strings.IndexByte(allowed, c) < 0 {
+			return fmt.Errorf("%w %q in %q", ErrUnsafe, c, s)
+		}
+	}
+	return nil
+}
+
+// ControlPolicy states how text values treat control characters other than HTAB
+// and line breaks, which are not allowed by RFC-5545.
+type ControlPolicy int
+
+const (
+	// RejectControls causes writing to fail with an error wrapping ErrUnsafe.
+	RejectControls ControlPolicy = iota
+	// StripControls silently removes the control characters.
+	StripControls
+)
+
 //-------------------------------------------------------------------------------------------------
 
-// MaxLineLength is the maximum length of lines emitted by the line-folding
-// writer. It defaults to 75.
-var MaxLineLength = 75
+// DefaultLineLength is the maximum length of lines, in octets excluding the line
+// ending, required by RFC-5545.
+const DefaultLineLength = 75
+
+// Options controls the layout of the lines written by a fold writer or Buffer, and
+// how values are encoded within them. The zero value gives standard iCalendar
+// formatting. Each writer keeps its own copy of
+// the options, so writers with different options can be used concurrently.
+type Options struct {
+	// LineLength is the maximum length of lines in octets, excluding the line ending.
+	// It defaults to DefaultLineLength if zero.
+	LineLength int
+
+	// LineEnding is usually "\r\n", which is the default if blank, or "\n" for
+	// non-transmission purposes, e.g. for viewing.
+	LineEnding string
+
+	// NoFolding disables line folding so that lines can be of any length. This does
+	// not conform to RFC-5545 but can be useful for viewing.
+	NoFolding bool
+
+	// NoCaretEncoding disables the encoding of parameter values given by RFC-6868,
+	// in which "^" is written as "^^", DQUOTE as "^'" and line breaks as "^n". Set
+	// it for legacy consumers that do not understand the encoding; parameter values
+	// containing DQUOTE or line breaks are then rejected. Parsing always decodes the
+	// encoding.
+	//
+	// See https://tools.ietf.org/html/rfc6868
+	NoCaretEncoding bool
+
+	// TextControls is the policy used when writing TEXT values, including lists
+	// such as categories. It defaults to RejectControls.
+	TextControls ControlPolicy
+}
+
+// OptionsOf gets the options of a writer made by this package, or the zero Options
+// for other writers. Values use this to find how they should be written.
+func OptionsOf(w StringWriter) Options {
+	switch x := w.(type) {
+	case *lineBuffer:
+		return x.opts
+	case *foldWriter:
+		return x.opts
+	}
+	return Options{}
+}
 
 // foldWriter implements the max-75 character line folding.
 // It also collapses any i/o errors.
 type foldWriter struct {
 	w          *bufio.Writer
 	n          int
+	max        int // zero disables folding
 	err        error
+	scratch    []byte // reused by WriteTime
 	lineEnding string // usually "\r\n"
+	opts       Options
 }
 
 // NewFoldWriter returns a StringWriter wrapping an io.Writer that folds
-// lines at the 75th column (determined by MaxLineLength). The line
-// ending defaults to "\r\n" if blank.
+// lines at the 75th octet. Lines are folded early where necessary so that
+// multi-byte UTF-8 characters are never split. The line ending defaults to
+// "\r\n" if blank.
 //
 // The data is buffered; therefore Flush must be called at the end.
 func NewFoldWriter(w io.Writer, lineEnding string) StringWriter {
-	if lineEnding == "" {
-		lineEnding = "\r\n"
-	}
-	return &foldWriter{w: bufio.NewWriter(w), lineEnding: lineEnding}
+	return Options{LineEnding: lineEnding}.NewFoldWriter(w)
 }
 
-func (fw *foldWriter) Write(s []byte) (i int, err error) {
-	if fw.err != nil {
-		return
-	}
+// NewFoldWriter returns a StringWriter wrapping an io.Writer that folds lines
+// as specified by the options.
+//
+// The data is buffered; therefore Flush must be called at the end.
+func (o Options) NewFoldWriter(w io.Writer) StringWriter {
+	return o.newFoldWriter(w)
+}
 
-	remaining := MaxLineLength - fw.n
+func (o Options) newFoldWriter(w io.Writer) *foldWriter {
+	fw := &foldWriter{w: bufio.NewWriter(w), max: o.LineLength, lineEnding: o.LineEnding, opts: o}
+	if fw.lineEnding == "" {
+		fw.lineEnding = "\r\n"
+	}
+	switch {
+	case o.NoFolding:
+		fw.max = 0
+	case fw.max <= 0:
+		fw.max = DefaultLineLength
+	}
+	return fw
+}
 
+func (fw *foldWriter) Write(s []byte) (i int, err error) {
 	for i = 0; i < len(s) && fw.err == nil; i++ {
-		c := s[i]
-		if remaining < 1 {
-			fw.wrapLine()
-			remaining = MaxLineLength
-		}
-
-		fw.err = fw.w.WriteByte(c)
-		fw.n++
-		remaining--
+		fw.writeByte(s[i])
 	}
 
 	return i, fw.err
@@ -86,13 +173,38 @@ func (fw *foldWriter) WriteByte(c byte) error {
 		return fw.err
 	}
 
-	if fw.n >= MaxLineLength {
-		fw.wrapLine()
+	fw.writeByte(c)
+	return fw.err
+}
+
+// writeByte writes one byte, folding the line first if necessary. Lines are never
+// folded within a UTF-8 sequence: if a multi-byte sequence starts with c and it
+// would not fit on the current line, the line is folded before it.
+func (fw *foldWriter) writeByte(c byte) {
+	if fw.max > 0 {
+		remaining := fw.max - fw.n
+		if remaining < 1 || (remaining < 4 && utf8.RuneStart(c) && sequenceLength(c) > remaining) {
+			fw.wrapLine()
+		}
 	}
 
-	fw.err = fw.w.WriteByte(c)
-	fw.n++
-	return fw.err
+	if fw.err == nil {
+		fw.err = fw.w.WriteByte(c)
+		fw.n++
+	}
+}
+
+// sequenceLength gives the length of the UTF-8 sequence that starts with a byte.
+func sequenceLength(c byte) int {
+	switch {
+	case c >= 0xF0:
+		return 4
+	case c >= 0xE0:
+		return 3
+	case c >= 0xC0:
+		return 2
+	}
+	return 1
 }
 
 func (fw *foldWriter) wrapLine() error {
@@ -107,7 +219,11 @@ func (fw *foldWriter) wrapLine() error {
 
 func (fw *foldWriter) WriteString(s string) (n int, err error) {
 	// treat s as a sequence of bytes, not runes
-	return fw.Write([]byte(s))
+	for n = 0; n < len(s) && fw.err == nil; n++ {
+		fw.writeByte(s[n])
+	}
+
+	return n, fw.err
 }
 
 func (fw *foldWriter) newline() error {
@@ -127,20 +243,71 @@ func (fw *foldWriter) Flush() error {
 	return fw.err
 }
 
+// WriteTime writes a time formatted using a layout, as for time.Time.Format. The
+// writers in this package do this without allocating.
+func WriteTime(w StringWriter, t time.Time, layout string) error {
+	switch x := w.(type) {
+	case *lineBuffer:
+		x.data = t.AppendFormat(x.data, layout)
+		return nil
+	case *foldWriter:
+		x.scratch = t.AppendFormat(x.scratch[:0], layout)
+		_, err := x.Write(x.scratch)
+		return err
+	}
+
+	_, err := w.WriteString(t.Format(layout))
+	return err
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// lineBuffer holds one content line before it is written, so that a property is
+// written whole or not at all. It has the same options as the fold writer.
+type lineBuffer struct {
+	data []byte
+	opts Options
+}
+
+func (lb *lineBuffer) Write(p []byte) (int, error) {
+	lb.data = append(lb.data, p...)
+	return len(p), nil
+}
+
+func (lb *lineBuffer) WriteByte(c byte) error {
+	lb.data = append(lb.data, c)
+	return nil
+}
+
+func (lb *lineBuffer) WriteString(s string) (int, error) {
+	lb.data = append(lb.data, s...)
+	return len(s), nil
+}
+
 //-------------------------------------------------------------------------------------------------
 
 // Buffer wraps bufio.Writer with some iCalendar-specific helper methods.
 // It folds long lines to meet the iCalendar max-75 characters per line limit.
 // If coallesces errors so they don't have to be checked after every method;
 // it is sufficient to check once at the end.
+//
+// Properties are written atomically: if a value cannot be written, none of its
+// content line is written, the error is retained and nothing more is written.
 type Buffer struct {
-	fw *foldWriter
+	fw   *foldWriter
+	line lineBuffer // reused for each property
 }
 
 // NewBuffer constructs a Buffer that wraps some Writer. The lineEnding can be
 // "" or "\r\n" for normal iCalendar formatting, or "\n" in other cases.
 func NewBuffer(w io.Writer, lineEnding string) *Buffer {
-	return &Buffer{NewFoldWriter(w, lineEnding).(*foldWriter)}
+	return Options{LineEnding: lineEnding}.NewBuffer(w)
+}
+
+// NewBuffer constructs a Buffer that wraps some Writer, laying out lines as
+// specified by the options.
+func (o Options) NewBuffer(w io.Writer) *Buffer {
+	return &Buffer{fw: o.newFoldWriter(w), line: lineBuffer{opts: o}}
 }
 
 // WriteString writes the string supplied.
@@ -158,14 +325,21 @@ func (b *Buffer) WriteLine(s string) error {
 }
 
 // WriteValuerLine conditionally writes a valuer along with its property name. If
-// the predicate is false, nothing is written.
+// the predicate is false, nothing is written. The content line is assembled before
+// it is written, so if the valuer fails, for example because its value is unsafe,
+// none of the line is written. The error is retained and nothing more is written.
 func (b *Buffer) WriteValuerLine(predicate bool, label string, v Valuer) error {
-	if !predicate {
+	if !predicate || b.fw.err != nil {
 		return b.fw.err // skip
 	}
 
-	b.WriteString(label)
-	v.WriteTo(b.fw)
+	b.line.data = append(b.line.data[:0], label...)
+	if err := v.WriteTo(&b.line); err != nil {
+		b.fw.err = fmt.Errorf("%s: %w", label, err)
+		return b.fw.err
+	}
+
+	b.fw.Write(b.line.data)
 	return b.fw.newline()
 }
 
@@ -0,0 +1,124 @@
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LineReader reads iCalendar content lines from some Reader. It reverses the
+// line-folding algorithm, so each line returned is one complete logical line.
+// Both "\r\n" and "\n" line endings are accepted.
+type LineReader struct {
+	r       *bufio.Reader
+	pending string
+	hasNext bool
+	line    int // the number of the physical line most recently read
+	next    int // the number of the physical line held in pending
+	start   int // the number of the first physical line of the current logical line
+}
+
+// NewLineReader returns a LineReader that reads from r.
+func NewLineReader(r io.Reader) *LineReader {
+	return &LineReader{r: bufio.NewReader(r)}
+}
+
+// Line returns the (1-based) physical line number at which the logical line most
+// recently returned by ReadLine started. This is useful in error messages.
+func (lr *LineReader) Line() int {
+	return lr.start
+}
+
+// ReadLine returns the next unfolded content line, without its line ending.
+// Blank lines are skipped. At the end of the input, it returns io.EOF.
+func (lr *LineReader) ReadLine() (string, error) {
+	for {
+		s, err := lr.readLogicalLine()
+		if err != nil || s != "" {
+			return s, err
+		}
+	}
+}
+
+func (lr *LineReader) readLogicalLine() (string, error) {
+	var sb strings.Builder
+
+	if lr.hasNext {
+		sb.WriteString(lr.pending)
+		lr.start = lr.next
+		lr.hasNext = false
+	} else {
+		s, err := lr.readPhysicalLine()
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(s)
+		lr.start = lr.line
+	}
+
+	for {
+		s, err := lr.readPhysicalLine()
+		if err == io.EOF {
+			return sb.String(), nil
+		} else if err != nil {
+			return "", err
+		}
+
+		if len(s) > 0 && (s[0] == ' ' || s[0] == '\t') {
+			// a folded continuation line
+			sb.WriteString(s[1:])
+		} else {
+			lr.pending = s
+			lr.next = lr.line
+			lr.hasNext = true
+			return sb.String(), nil
+		}
+	}
+}
+
+func (lr *LineReader) readPhysicalLine() (string, error) {
+	s, err := lr.r.ReadString('\n')
+	if err == io.EOF && s != "" {
+		err = nil // the last line has no line ending
+	}
+	if err != nil {
+		return "", err
+	}
+	lr.line++
+	s = strings.TrimSuffix(s, "\n")
+	s = strings.TrimSuffix(s, "\r")
+	return s, nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// SplitContentLine splits an unfolded content line into its name, its parameters
+// and its value. The name is converted to upper case. The parameters are returned
+// as unparsed text, which is either blank or starts with a semicolon.
+//
+// Colons and semicolons within quoted parameter values are handled correctly.
+//
+// See https://tools.ietf.org/html/rfc5545#section-3.1
+func SplitContentLine(line string) (name, params, value string, err error) {
+	nameEnd := strings.IndexAny(line, ";:")
+	if nameEnd <= 0 {
+		return "", "", "", fmt.Errorf("%q is not a valid content line", line)
+	}
+
+	name = strings.ToUpper(line[:nameEnd])
+
+	quoted := false
+	for i := nameEnd; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				return name, line[nameEnd:i], line[i+1:], nil
+			}
+		}
+	}
+
+	return "", "", "", fmt.Errorf("%q has no value", line)
+}
@@ -0,0 +1,344 @@
+package ical2
+
+import (
+	"fmt"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"time"
+)
+
+// PropertyPolicy decides which calendar supplies a calendar-level property
+// when calendars are merged.
+type PropertyPolicy int
+
+const (
+	// FirstWins takes the value from the first calendar that defines the property.
+	FirstWins PropertyPolicy = iota
+	// LastWins takes the value from the last calendar that defines the property.
+	LastWins
+)
+
+// Merger combines several calendars into one. The zero value is ready to use.
+type Merger struct {
+	// Policy applies to calendar-level properties that are not listed in Policies.
+	Policy PropertyPolicy
+
+	// Policies, if not nil, overrides Policy for particular properties, keyed by
+	// property name (e.g. "NAME").
+	Policies map[string]PropertyPolicy
+
+	// Template, if not nil, provides calendar-level properties that take
+	// precedence over those in the merged calendars, e.g. the PRODID and NAME of
+	// the combined feed. Its components are not used.
+	Template *VCalendar
+}
+
+// Conflict describes calendars that disagree during a merge.
+type Conflict struct {
+	// Name is the calendar property name or the component name, e.g. "NAME"
+	// or "VEVENT".
+	Name string
+
+	// UID and RecurrenceId identify a conflicting component; they are blank
+	// for calendar properties. For time zones, UID holds the TZID.
+	UID, RecurrenceId string
+
+	// Chosen is the index of the calendar whose version was kept.
+	Chosen int
+
+	// Rejected are the indexes of the calendars whose versions were discarded.
+	Rejected []int
+
+	// Unresolved is true when the versions could not be ordered by SEQUENCE,
+	// DTSTAMP or LAST-MODIFIED, so the first one was kept.
+	Unresolved bool
+}
+
+// String summarises the conflict.
+func (c Conflict) String() string {
+	name := c.Name
+	if c.UID != "" {
+		name += " " + c.UID
+	}
+	if c.RecurrenceId != "" {
+		name += " " + c.RecurrenceId
+	}
+
+	s := fmt.Sprintf("%s: kept calendar %d, rejected %v", name, c.Chosen, c.Rejected)
+	if c.Unresolved {
+		s += " (unresolved)"
+	}
+	return s
+}
+
+// Merge combines calendars using the default Merger.
+func Merge(calendars ...*VCalendar) (*VCalendar, []Conflict, error) {
+	return Merger{}.Merge(calendars...)
+}
+
+// Merge combines calendars into one. Components are de-duplicated by UID and
+// RECURRENCE-ID. When several calendars contain different versions of a
+// component, the one with the highest SEQUENCE is kept, then the one with the
+// latest DTSTAMP, then the one with the latest LAST-MODIFIED. Versions that
+// differ only in DTSTAMP or LAST-MODIFIED are not treated as conflicting.
+//
+// Time zone components (VTIMEZONE) are de-duplicated by TZID and only those
+// referenced by a TZID parameter in the merged components are kept; they are
+// placed before all other components.
+//
+// Calendar-level properties are chosen according to the policy. All
+// disagreements are reported as conflicts, in order of discovery.
+//
+// The merged calendar shares its components with the input calendars.
+func (m Merger) Merge(calendars ...*VCalendar) (*VCalendar, []Conflict, error) {
+	out := &VCalendar{}
+	var conflicts []Conflict
+
+	m.mergeProperties(out, calendars, &conflicts)
+
+	var order []string
+	entries := make(map[string]*mergeEntry)
+	var zones []string
+	zoneEntries := make(map[string]*mergeEntry)
+	referenced := make(map[string]bool)
+
+	for ci, c := range calendars {
+		for _, component := range c.VComponent {
+			raw, err := rawOf(component, c.Method)
+			if err != nil {
+				return nil, nil, fmt.Errorf("calendar %d: %w", ci, err)
+			}
+
+			e := newMergeEntry(ci, component, raw)
+
+			if raw.Name == "VTIMEZONE" {
+				if first, exists := zoneEntries[e.tzid]; exists {
+					if len(diffComponents(first.raw, raw)) > 0 {
+						conflicts = append(conflicts, Conflict{Name: raw.Name, UID: e.tzid, Chosen: first.calendar, Rejected: []int{ci}})
+					}
+				} else {
+					zones = append(zones, e.tzid)
+					zoneEntries[e.tzid] = e
+				}
+				continue
+			}
+
+			if first, exists := entries[e.key]; exists {
+				first.versions = append(first.versions, e)
+			} else {
+				order = append(order, e.key)
+				entries[e.key] = e
+			}
+		}
+	}
+
+	var components []VComponent
+	for _, key := range order {
+		winner, conflict := entries[key].resolve()
+		if conflict != nil {
+			conflicts = append(conflicts, *conflict)
+		}
+		winner.raw.references(referenced)
+		components = append(components, winner.component)
+	}
+
+	for _, tzid := range zones {
+		if referenced[tzid] {
+			out.VComponent = append(out.VComponent, zoneEntries[tzid].component)
+		}
+	}
+	out.VComponent = append(out.VComponent, components...)
+
+	return out, conflicts, nil
+}
+
+func (m Merger) policy(name string) PropertyPolicy {
+	if p, exists := m.Policies[name]; exists {
+		return p
+	}
+	return m.Policy
+}
+
+func (m Merger) mergeProperties(out *VCalendar, cc []*VCalendar, conflicts *[]Conflict) {
+	out.Version = value.Text("2.0")
+	out.ProdId = mergeProperty(m, "PRODID", cc, func(c *VCalendar) value.TextValue { return c.ProdId }, conflicts)
+	out.CalScale = mergeProperty(m, "CALSCALE", cc, func(c *VCalendar) value.TextValue { return c.CalScale }, conflicts)
+	out.Method = mergeProperty(m, "METHOD", cc, func(c *VCalendar) value.TextValue { return c.Method }, conflicts)
+	out.Name = mergeProperty(m, "NAME", cc, func(c *VCalendar) value.TextValue { return c.Name }, conflicts)
+	out.Description = mergeProperty(m, "DESCRIPTION", cc, func(c *VCalendar) value.TextValue { return c.Description }, conflicts)
+	out.URL = mergeProperty(m, "URL", cc, func(c *VCalendar) value.TextValue { return c.URL }, conflicts)
+	out.Source = mergeProperty(m, "SOURCE", cc, func(c *VCalendar) value.URIValue { return c.Source }, conflicts)
+	out.LastModified = mergeProperty(m, "LAST-MODIFIED", cc, func(c *VCalendar) value.DateTimeValue { return c.LastModified }, conflicts)
+	out.RecurrenceId = mergeProperty(m, "RECURRENCE-ID", cc, func(c *VCalendar) value.DateTimeValue { return c.RecurrenceId }, conflicts)
+	out.RefreshInterval = mergeProperty(m, "REFRESH-INTERVAL", cc, func(c *VCalendar) value.DurationValue { return c.RefreshInterval }, conflicts)
+	out.Color = mergeProperty(m, "COLOR", cc, func(c *VCalendar) value.TextValue { return c.Color }, conflicts)
+
+	// extensions are combined, omitting exact duplicates
+	seen := make(map[string]bool)
+	for _, c := range append([]*VCalendar{m.Template}, cc...) {
+		if c == nil {
+			continue
+		}
+		for _, x := range c.Extensions {
+			k := x.Key + ":" + valuerString(x.Value)
+			if !seen[k] {
+				seen[k] = true
+				out.Extensions = append(out.Extensions, x)
+			}
+		}
+	}
+}
+
+// mergeProperty chooses the value of one calendar-level property.
+func mergeProperty[V ics.Valuer](m Merger, name string, cc []*VCalendar, get func(*VCalendar) V, conflicts *[]Conflict) V {
+	if m.Template != nil && ics.IsDefined(get(m.Template)) {
+		return get(m.Template)
+	}
+
+	chosen := -1
+	var result V
+	var others []int
+
+	for i, c := range cc {
+		v := get(c)
+		if !ics.IsDefined(v) {
+			continue
+		}
+
+		switch {
+		case chosen < 0:
+			chosen, result = i, v
+		case valuerString(v) == valuerString(result):
+			// agreement is not a conflict
+		case m.policy(name) == LastWins:
+			others = append(others, chosen)
+			chosen, result = i, v
+		default:
+			others = append(others, i)
+		}
+	}
+
+	if len(others) > 0 {
+		*conflicts = append(*conflicts, Conflict{Name: name, Chosen: chosen, Rejected: others})
+	}
+	return result
+}
+
+func valuerString(v ics.Valuer) string {
+	if !ics.IsDefined(v) {
+		return ""
+	}
+	buf := &strings.Builder{}
+	v.WriteTo(buf)
+	return buf.String()
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type mergeEntry struct {
+	key          string
+	uid          string
+	recurrenceId string
+	tzid         string
+	sequence     int
+	dtStamp      time.Time
+	lastModified time.Time
+	calendar     int
+	component    VComponent
+	raw          *rawComponent
+	versions     []*mergeEntry // later versions with the same key
+}
+
+func newMergeEntry(calendar int, component VComponent, raw *rawComponent) *mergeEntry {
+	e := &mergeEntry{calendar: calendar, component: component, raw: raw}
+
+	for _, p := range raw.Properties {
+		switch p.Name {
+		case "UID":
+			e.uid = p.Value
+		case "RECURRENCE-ID":
+			if dt, err := value.ParseDateTime(p.Parameters, p.Value); err == nil {
+				e.recurrenceId = dt.Value.UTC().Format(time.RFC3339)
+			} else {
+				e.recurrenceId = p.Value
+			}
+		case "TZID":
+			e.tzid = p.Value
+		case "SEQUENCE":
+			if v, err := value.ParseInteger(p.Parameters, p.Value); err == nil {
+				e.sequence = v.Value
+			}
+		case "DTSTAMP":
+			if dt, err := value.ParseDateTime(p.Parameters, p.Value); err == nil {
+				e.dtStamp = dt.Value
+			}
+		case "LAST-MODIFIED":
+			if dt, err := value.ParseDateTime(p.Parameters, p.Value); err == nil {
+				e.lastModified = dt.Value
+			}
+		}
+	}
+
+	e.key = raw.Name + "\x00" + e.uid + "\x00" + e.recurrenceId
+	return e
+}
+
+// compare orders two versions of a component: positive if e is newer than f,
+// negative if it is older and zero if they cannot be ordered.
+func (e *mergeEntry) compare(f *mergeEntry) int {
+	switch {
+	case e.sequence != f.sequence:
+		return e.sequence - f.sequence
+	case !e.dtStamp.Equal(f.dtStamp):
+		return e.dtStamp.Compare(f.dtStamp)
+	}
+	return e.lastModified.Compare(f.lastModified)
+}
+
+// resolve chooses the winning version. It returns a conflict if the versions differ.
+func (e *mergeEntry) resolve() (*mergeEntry, *Conflict) {
+	winner := e
+	unresolved := false
+	for _, v := range e.versions {
+		switch c := v.compare(winner); {
+		case c > 0:
+			winner = v
+			unresolved = false
+		case c == 0 && len(diffComponents(winner.raw, v.raw)) > 0:
+			unresolved = true
+		}
+	}
+
+	var rejected []int
+	for _, v := range append([]*mergeEntry{e}, e.versions...) {
+		if v != winner && len(diffComponents(winner.raw, v.raw)) > 0 {
+			rejected = append(rejected, v.calendar)
+		}
+	}
+
+	if len(rejected) == 0 {
+		return winner, nil
+	}
+
+	return winner, &Conflict{
+		Name:         e.raw.Name,
+		UID:          e.uid,
+		RecurrenceId: e.recurrenceId,
+		Chosen:       winner.calendar,
+		Rejected:     rejected,
+		Unresolved:   unresolved,
+	}
+}
+
+// references adds the TZID parameters used in the component to a set.
+func (c *rawComponent) references(set map[string]bool) {
+	for _, p := range c.Properties {
+		if tzid, exists := p.Parameters.Get("TZID"); exists {
+			set[tzid.Value] = true
+		}
+	}
+	for _, sub := range c.Components {
+		sub.references(set)
+	}
+}
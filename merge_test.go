@@ -0,0 +1,114 @@
+package ical2_test
+
+import (
+	"fmt"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"testing"
+)
+
+// timeZone is a minimal VTIMEZONE component, sufficient for testing merges.
+type timeZone struct {
+	tzid, offset string
+}
+
+func (z timeZone) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+	b.WriteLine("BEGIN:VTIMEZONE")
+	b.WriteLine("TZID:" + z.tzid)
+	b.WriteLine("BEGIN:STANDARD")
+	b.WriteLine("DTSTART:19700101T000000")
+	b.WriteLine("TZOFFSETFROM:" + z.offset)
+	b.WriteLine("TZOFFSETTO:" + z.offset)
+	b.WriteLine("END:STANDARD")
+	b.WriteLine("END:VTIMEZONE")
+	return b.Flush()
+}
+
+func mergeCalendar(t *testing.T, props string, events ...string) *ical2.VCalendar {
+	t.Helper()
+	text := "BEGIN:VCALENDAR\nPRODID:-//Test//EN\nVERSION:2.0\n" + props + strings.Join(events, "") + "END:VCALENDAR\n"
+	c, err := ical2.Decode(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func mergeEvent(uid string, seq int, stamp, summary string) string {
+	return fmt.Sprintf("BEGIN:VEVENT\nDTSTART;TZID=Europe/London:20240102T100000\nDTSTAMP:%s\nUID:%s\nSUMMARY:%s\nSEQUENCE:%d\nEND:VEVENT\n", stamp, uid, summary, seq)
+}
+
+func TestMerge(t *testing.T) {
+	a := mergeCalendar(t, "NAME:Team A\nCOLOR:red\n",
+		mergeEvent("1", 0, "20240101T000000Z", "Shared"),
+		mergeEvent("2", 1, "20240101T000000Z", "Old"),
+		mergeEvent("3", 0, "20240101T000000Z", "Newer stamp"))
+	a.With(timeZone{"Europe/London", "+0000"}).With(timeZone{"Europe/Paris", "+0100"})
+
+	b := mergeCalendar(t, "NAME:Team B\n",
+		mergeEvent("1", 0, "20240201T000000Z", "Shared"),
+		mergeEvent("2", 2, "20231201T000000Z", "New"),
+		mergeEvent("3", 0, "20231201T000000Z", "Older stamp"),
+		mergeEvent("4", 0, "20240101T000000Z", "Only B"))
+	b.With(timeZone{"Europe/London", "+0000"})
+
+	merged, conflicts, err := ical2.Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := merged.String()
+	for _, exp := range []string{"NAME:Team A\n", "COLOR:red\n", "SUMMARY:New\n", "SUMMARY:Newer stamp\n", "SUMMARY:Only B\n"} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("missing %q in\n%s", exp, s)
+		}
+	}
+
+	if strings.Count(s, "BEGIN:VTIMEZONE") != 1 || strings.Contains(s, "Europe/Paris") ||
+		strings.Index(s, "BEGIN:VTIMEZONE") > strings.Index(s, "BEGIN:VEVENT") {
+		t.Errorf("got\n%s", s)
+	}
+
+	if strings.Count(s, "BEGIN:VEVENT") != 4 {
+		t.Errorf("got\n%s", s)
+	}
+
+	var got []string
+	for _, c := range conflicts {
+		got = append(got, c.String())
+	}
+	exp := []string{
+		"NAME: kept calendar 0, rejected [1]",
+		"VEVENT 2: kept calendar 1, rejected [0]",
+		"VEVENT 3: kept calendar 0, rejected [1]",
+	}
+	if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("expected\n%s\nbut got\n%s", strings.Join(exp, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestMergePolicy(t *testing.T) {
+	a := mergeCalendar(t, "NAME:Team A\nCOLOR:red\n", mergeEvent("1", 0, "20240101T000000Z", "X"))
+	b := mergeCalendar(t, "NAME:Team B\nCOLOR:blue\n", mergeEvent("1", 0, "20240101T000000Z", "Y"))
+
+	m := ical2.Merger{
+		Policies: map[string]ical2.PropertyPolicy{"COLOR": ical2.LastWins},
+		Template: &ical2.VCalendar{ProdId: value.Text("-//Department//EN"), Name: value.Text("Department")},
+	}
+
+	merged, conflicts, err := m.Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merged.ProdId.Value != "-//Department//EN" || merged.Name.Value != "Department" || merged.Color.Value != "blue" {
+		t.Errorf("got\n%s", merged)
+	}
+
+	if len(conflicts) != 2 || conflicts[0].String() != "COLOR: kept calendar 1, rejected [0]" ||
+		conflicts[1].String() != "VEVENT 1: kept calendar 0, rejected [1] (unresolved)" {
+		t.Errorf("got %v", conflicts)
+	}
+}
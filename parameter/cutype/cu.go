@@ -36,3 +36,8 @@ func Unknown() parameter.Parameter {
 func CUType(v string) parameter.Parameter {
 	return parameter.Single(CUTYPE, v)
 }
+
+func init() {
+	parameter.Define(parameter.Definition{Key: CUTYPE, Properties: []string{"ATTENDEE"},
+		Values: []string{"INDIVIDUAL", "GROUP", "RESOURCE", "ROOM", "UNKNOWN"}, Extensible: true})
+}
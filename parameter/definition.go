@@ -0,0 +1,138 @@
+package parameter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Definition declares how a parameter may be used.
+type Definition struct {
+	// Key is the parameter name, e.g. "PARTSTAT".
+	Key string
+
+	// Properties lists the properties that the parameter may be used with, e.g.
+	// "ATTENDEE". If it is empty, the parameter may be used with any property.
+	Properties []string
+
+	// Values lists the enumerated values. If it is empty, any value is allowed.
+	Values []string
+
+	// Extensible allows x-name and iana-token values as well as the enumerated values.
+	Extensible bool
+}
+
+var (
+	// ErrNotApplicable is returned by Check when a parameter is used with a property
+	// that does not allow it.
+	ErrNotApplicable = errors.New("not applicable")
+
+	// ErrInvalidValue is returned by Check when a parameter value is not allowed.
+	ErrInvalidValue = errors.New("invalid value")
+
+	// ErrUnregisteredValue is returned by Check when an extensible parameter has
+	// an iana-token value that is not one of the enumerated values. This is allowed
+	// but is often a mistake.
+	ErrUnregisteredValue = errors.New("unregistered value")
+)
+
+var (
+	definitionsMu sync.RWMutex
+	definitions   = make(map[string]Definition)
+
+	ianaToken = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+// Define declares parameters, replacing any earlier definitions with the same keys.
+// The parameters in this package and its sub-packages define themselves.
+func Define(defs ...Definition) {
+	definitionsMu.Lock()
+	defer definitionsMu.Unlock()
+
+	for _, def := range defs {
+		def.Key = strings.ToUpper(def.Key)
+		definitions[def.Key] = def
+	}
+}
+
+// Lookup finds the definition of a parameter. The boolean result is false if there
+// is none.
+func Lookup(key string) (Definition, bool) {
+	definitionsMu.RLock()
+	defer definitionsMu.RUnlock()
+	def, exists := definitions[strings.ToUpper(key)]
+	return def, exists
+}
+
+// Definitions lists all the parameter definitions, ordered by key.
+func Definitions() []Definition {
+	definitionsMu.RLock()
+	defer definitionsMu.RUnlock()
+
+	list := make([]Definition, 0, len(definitions))
+	for _, def := range definitions {
+		list = append(list, def)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// AppliesTo tests whether the parameter may be used with a property.
+func (def Definition) AppliesTo(property string) bool {
+	if len(def.Properties) == 0 {
+		return true
+	}
+	for _, p := range def.Properties {
+		if strings.EqualFold(p, property) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check tests whether the parameter may be used with a property and whether its
+// values are allowed. Parameters without a definition, such as x-name parameters,
+// are not checked. The error wraps ErrNotApplicable, ErrInvalidValue or
+// ErrUnregisteredValue.
+func (p Parameter) Check(property string) error {
+	def, exists := Lookup(p.Key)
+	if !exists {
+		return nil
+	}
+
+	if !def.AppliesTo(property) {
+		return fmt.Errorf("%s %w to %s", def.Key, ErrNotApplicable, property)
+	}
+
+	if len(def.Values) == 0 {
+		return nil
+	}
+
+	for _, v := range append([]string{p.Value}, p.Others...) {
+		if err := def.checkValue(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (def Definition) checkValue(v string) error {
+	for _, allowed := range def.Values {
+		if strings.EqualFold(v, allowed) {
+			return nil
+		}
+	}
+
+	switch {
+	case !def.Extensible || !ianaToken.MatchString(v):
+		return fmt.Errorf("%s=%q: %w", def.Key, v, ErrInvalidValue)
+	case strings.HasPrefix(strings.ToUpper(v), "X-"):
+		return nil
+	}
+
+	return fmt.Errorf("%s=%q: %w", def.Key, v, ErrUnregisteredValue)
+}
@@ -0,0 +1,50 @@
+package parameter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParameterCheck(t *testing.T) {
+	Define(Definition{Key: "X-TEST-KIND", Properties: []string{"SUMMARY"}, Values: []string{"A", "B"}, Extensible: true})
+
+	cases := []struct {
+		p        Parameter
+		property string
+		err      error
+	}{
+		{Rsvp(true), "ATTENDEE", nil},
+		{Rsvp(true), "SUMMARY", ErrNotApplicable},
+		{Single(RSVP, "maybe"), "ATTENDEE", ErrInvalidValue},
+		{TZid("Europe/London"), "DTSTART", nil},
+		{TZid("Europe/London"), "dtstart", nil},
+		{TZid("Europe/London"), "DTSTAMP", ErrNotApplicable},
+		{RangeThisandfuture(), "RECURRENCE-ID", nil},
+		{Single(RANGE, "THISANDPRIOR"), "RECURRENCE-ID", ErrInvalidValue},
+		{Single("X-TEST-KIND", "a"), "SUMMARY", nil},
+		{Multiple("X-TEST-KIND", "A", "X-OTHER"), "SUMMARY", nil},
+		{Single("X-TEST-KIND", "OTHER"), "SUMMARY", ErrUnregisteredValue},
+		{Single("X-TEST-KIND", "not valid"), "SUMMARY", ErrInvalidValue},
+		{Single("X-UNDEFINED", "anything"), "SUMMARY", nil},
+	}
+
+	for i, c := range cases {
+		err := c.p.Check(c.property)
+		if (c.err == nil && err != nil) || !errors.Is(err, c.err) {
+			t.Errorf("%d: expected %v but got %v", i, c.err, err)
+		}
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	defs := Definitions()
+	for i := 1; i < len(defs); i++ {
+		if defs[i-1].Key >= defs[i].Key {
+			t.Errorf("%d: %s is out of order", i, defs[i].Key)
+		}
+	}
+
+	if def, exists := Lookup("tzid"); !exists || !def.AppliesTo("RDATE") || def.AppliesTo("SUMMARY") {
+		t.Errorf("got %+v", def)
+	}
+}
@@ -32,3 +32,8 @@ func Thumbnail() parameter.Parameter {
 func Display(v string) parameter.Parameter {
 	return parameter.Single(DISPLAY, v)
 }
+
+func init() {
+	parameter.Define(parameter.Definition{Key: DISPLAY, Properties: []string{"IMAGE"},
+		Values: []string{"BADGE", "GRAPHIC", "FULLSIZE", "THUMBNAIL"}, Extensible: true})
+}
@@ -28,3 +28,8 @@ const (
 func Feature(vv ...string) parameter.Parameter {
 	return parameter.Multiple(FEATURE, vv...)
 }
+
+func init() {
+	parameter.Define(parameter.Definition{Key: FEATURE, Properties: []string{"CONFERENCE"},
+		Values: []string{AUDIO, CHAT, FEED, MODERATOR, PHONE, SCREEN, VIDEO}, Extensible: true})
+}
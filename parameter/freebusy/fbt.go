@@ -31,3 +31,8 @@ func BusyTentative() parameter.Parameter {
 func Other(v string) parameter.Parameter {
 	return parameter.Single(FBTYPE, v)
 }
+
+func init() {
+	parameter.Define(parameter.Definition{Key: FBTYPE, Properties: []string{"FREEBUSY"},
+		Values: []string{"FREE", "BUSY", "BUSY-UNAVAILABLE", "BUSY-TENTATIVE"}, Extensible: true})
+}
@@ -10,6 +10,7 @@
 package parameter
 
 import (
+	"fmt"
 	"github.com/rickb777/ical2/ics"
 	"strings"
 )
@@ -45,42 +46,156 @@ func (p Parameter) Equals(q Parameter) bool {
 
 const dquote = '"'
 const comma = ','
+const caret = '^'
 
 // WriteTo serialises the parameter in iCalendar ics format to the writer.
 // Parameters with multiple values are serialised using a comma-separated list.
 //
 // Parameters with values containing a COLON character, a SEMICOLON character
 // or a COMMA character are placed in quoted text.
+//
+// Values are caret-encoded (RFC-6868), unless the writer's options have
+// NoCaretEncoding set; see ics.OptionsOf.
+//
+// Keys must be iana-tokens or x-names. Values must not contain control characters
+// other than HTAB and, when caret-encoded, line breaks, because these would corrupt
+// the content line. Likewise, they must not contain DQUOTE unless caret-encoded.
+// Otherwise, an error wrapping ics.ErrUnsafe is returned and nothing is written.
 func (p Parameter) WriteTo(w ics.StringWriter) error {
+	caretEncoding := !ics.OptionsOf(w).NoCaretEncoding
+	if err := p.check(caretEncoding); err != nil {
+		return err
+	}
+	p.write(w, caretEncoding)
+	return nil
+}
+
+func (p Parameter) write(w ics.StringWriter, caretEncoding bool) {
 	w.WriteString(p.Key)
 	w.WriteByte('=')
 
+	// caret encoding does not introduce any of these characters
 	needQuotes := strings.IndexAny(p.Value, ":;,") >= 0
 	for _, v := range p.Others {
 		needQuotes = needQuotes || strings.IndexAny(v, ":;,") >= 0
 	}
 
-	if needQuotes {
-		w.WriteByte(dquote)
-		w.WriteString(p.Value)
+	writeValue(w, p.Value, needQuotes, caretEncoding)
+	for _, v := range p.Others {
+		w.WriteByte(comma)
+		writeValue(w, v, needQuotes, caretEncoding)
+	}
+}
+
+func writeValue(w ics.StringWriter, v string, quoted, caretEncoding bool) {
+	if quoted {
 		w.WriteByte(dquote)
-		for _, v := range p.Others {
-			w.WriteByte(comma)
-			w.WriteByte(dquote)
-			w.WriteString(v)
-			w.WriteByte(dquote)
-		}
+	}
+
+	if caretEncoding {
+		caretEncode(w, v)
 	} else {
-		w.WriteString(p.Value)
-		for _, v := range p.Others {
-			w.WriteByte(comma)
-			w.WriteString(v)
+		w.WriteString(v)
+	}
+
+	if quoted {
+		w.WriteByte(dquote)
+	}
+}
+
+// check rejects keys and values that would corrupt the content line.
+func (p Parameter) check(caretEncoding bool) error {
+	if !ianaToken.MatchString(p.Key) {
+		return fmt.Errorf("parameter %q: %w in key", p.Key, ics.ErrUnsafe)
+	}
+
+	if err := p.checkValue(p.Value, caretEncoding); err != nil {
+		return err
+	}
+	for _, v := range p.Others {
+		if err := p.checkValue(v, caretEncoding); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+func (p Parameter) checkValue(v string, caretEncoding bool) error {
+	allowed := "\t"
+	if caretEncoding {
+		allowed = "\t\r\n"
+	} else if strings.IndexByte(v, dquote) >= 0 {
+		return fmt.Errorf("%s: %w '\"' in %q", p.Key, ics.ErrUnsafe, v)
+	}
+
+	if err := ics.CheckControls(v, allowed); err != nil {
+		return fmt.Errorf("%s: %w", p.Key, err)
+	}
+	return nil
+}
+
+// caretEncode writes s applying the RFC-6868 encoding. CRLF and lone CR are
+// treated as line breaks.
+func caretEncode(w ics.StringWriter, s string) {
+	start := 0
+	for i := 0; i < len(s); i++ {
+		end := i
+		var encoded string
+		switch s[i] {
+		case caret:
+			encoded = "^^"
+		case dquote:
+			encoded = "^'"
+		case '\r':
+			encoded = "^n"
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+			encoded = "^n"
+		default:
+			continue
+		}
+
+		w.WriteString(s[start:end])
+		w.WriteString(encoded)
+		start = i + 1
+	}
+	w.WriteString(s[start:])
+}
+
+// caretDecode reverses caretEncode. A caret followed by any other character is
+// left unchanged, as required by RFC-6868.
+func caretDecode(s string) string {
+	if strings.IndexByte(s, caret) < 0 {
+		return s
+	}
+
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == caret && i+1 < len(s) {
+			switch s[i+1] {
+			case '^':
+				b.WriteByte(caret)
+				i++
+				continue
+			case '\'':
+				b.WriteByte(dquote)
+				i++
+				continue
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
 //-------------------------------------------------------------------------------------------------
 
 // Single returns a Parameter with a single string value.
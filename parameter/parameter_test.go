@@ -2,6 +2,9 @@ package parameter
 
 import (
 	"bytes"
+	"errors"
+	"github.com/rickb777/ical2/ics"
+	"strings"
 	"testing"
 )
 
@@ -58,9 +61,108 @@ func TestParameterConstructorsAndWriteTo(t *testing.T) {
 	}
 }
 
+func TestParameterUnsafe(t *testing.T) {
+	cases := []Parameter{
+		CommonName("Joe\x00"),
+		Member("a", "b\x7f"),
+		Single("X-BAD KEY", "v"),
+		Single("X-BAD\nKEY", "v"),
+	}
+
+	for i, p := range cases {
+		b := &bytes.Buffer{}
+		err := Parameters{Language("en"), p}.WriteTo(b)
+		if !errors.Is(err, ics.ErrUnsafe) || b.Len() != 0 {
+			t.Errorf("%d: got %v %q", i, err, b.String())
+		}
+	}
+
+	if err := CommonName("tab\there").WriteTo(&bytes.Buffer{}); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestCaretEncoding(t *testing.T) {
+	cases := []struct {
+		v   Parameter
+		exp string
+	}{
+		{CommonName(`Patrick O"Brien`), `CN=Patrick O^'Brien`},
+		{CommonName("Joe\r\nORGANIZER:mailto:evil@example.com"), `CN="Joe^nORGANIZER:mailto:evil@example.com"`},
+		{CommonName("a\rb\nc"), `CN=a^nb^nc`},
+		{Member("^_^", `"x"`), `MEMBER=^^_^^,^'x^'`},
+	}
+
+	for i, c := range cases {
+		b := &bytes.Buffer{}
+		if err := c.v.WriteTo(b); err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+		if b.String() != c.exp {
+			t.Errorf("%d: expected %q but got %q", i, c.exp, b.String())
+		}
+
+		pp, err := Parse(";" + b.String())
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+		decoded := strings.ReplaceAll(strings.ReplaceAll(c.v.Value, "\r\n", "\n"), "\r", "\n")
+		if pp[0].Value != decoded || len(pp[0].Others) != len(c.v.Others) {
+			t.Errorf("%d: got %q", i, pp[0])
+		}
+	}
+
+	if pp, _ := Parse(";CN=a^b^"); pp[0].Value != "a^b^" {
+		t.Errorf("got %q", pp[0].Value)
+	}
+
+	legacy := ics.Options{NoCaretEncoding: true}
+
+	for i, p := range []Parameter{CommonName(`O"Brien`), CommonName("a\nb")} {
+		if err := p.WriteTo(legacy.NewFoldWriter(&bytes.Buffer{})); !errors.Is(err, ics.ErrUnsafe) {
+			t.Errorf("%d: got %v", i, err)
+		}
+	}
+
+	b := &bytes.Buffer{}
+	x := legacy.NewFoldWriter(b)
+	Parameters{CommonName("^_^")}.WriteTo(x)
+	x.(ics.Flusher).Flush()
+	if b.String() != ";CN=^_^" {
+		t.Errorf("got %q", b.String())
+	}
+}
+
 func assertTrue(t *testing.T, predicate bool, hint string, args ...interface{}) {
 	t.Helper()
 	if !predicate {
 		t.Errorf(hint, args...)
 	}
 }
+
+func TestParametersDoNotAlias(t *testing.T) {
+	base := make(Parameters, 0, 4).Append(CommonName("a"), Language("en"))
+
+	x := base.Append(Rsvp(true))
+	y := base.Append(Rsvp(false))
+	z := base.RemoveByKey("CN")
+
+	cases := []struct {
+		pp  Parameters
+		exp string
+	}{
+		{base, ";CN=a;LANGUAGE=en"},
+		{x, ";CN=a;LANGUAGE=en;RSVP=TRUE"},
+		{y, ";CN=a;LANGUAGE=en;RSVP=FALSE"},
+		{z, ";LANGUAGE=en"},
+		{base.Append(CommonName("b")), ";LANGUAGE=en;CN=b"},
+	}
+
+	for i, c := range cases {
+		b := &bytes.Buffer{}
+		c.pp.WriteTo(b)
+		if s := b.String(); s != c.exp {
+			t.Errorf("%d: expected %q but got %q", i, c.exp, s)
+		}
+	}
+}
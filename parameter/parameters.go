@@ -9,32 +9,55 @@ import (
 type Parameters []Parameter
 
 // WriteTo serialises the parameters in iCalendar ics format to the writer.
+// If any parameter is unsafe (see Parameter.WriteTo), nothing is written.
 func (pp Parameters) WriteTo(w ics.StringWriter) error {
+	caretEncoding := !ics.OptionsOf(w).NoCaretEncoding
+	for _, p := range pp {
+		if err := p.check(caretEncoding); err != nil {
+			return err
+		}
+	}
+
 	for _, p := range pp {
 		w.WriteByte(';')
-		p.WriteTo(w)
+		p.write(w, caretEncoding)
 	}
 
 	return nil
 }
 
-// RemoveByKey removes all parameters with a key (or keys) from the list.
+// RemoveByKey removes all parameters with a key (or keys) from the list. The
+// receiver is not altered; if there is nothing to remove, it is returned as is.
 func (pp Parameters) RemoveByKey(key ...string) Parameters {
-	for i := 0; i < len(pp); i++ {
-		for _, k := range key {
-			// the length might have changed: need to check it again
-			if i < len(pp) {
-				k = strings.ToUpper(k)
-				if pp[i].Key == k {
-					pp = append(pp[:i], pp[i+1:]...)
-				}
+	var result Parameters
+	for i, p := range pp {
+		if p.hasKey(key) {
+			if result == nil {
+				result = make(Parameters, i, len(pp)-1)
+				copy(result, pp[:i])
 			}
+		} else if result != nil {
+			result = append(result, p)
 		}
 	}
-	return pp
+
+	if result == nil {
+		return pp
+	}
+	return result
 }
 
-// Prepend appends a parameter (or parameters), ensuring that keys remain unique.
+func (p Parameter) hasKey(keys []string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(p.Key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prepend prepends a parameter (or parameters), ensuring that keys remain unique.
+// The receiver is not altered.
 func (pp Parameters) Prepend(ps ...Parameter) Parameters {
 	for _, p := range ps {
 		p.Key = strings.ToUpper(p.Key)
@@ -44,10 +67,32 @@ func (pp Parameters) Prepend(ps ...Parameter) Parameters {
 }
 
 // Append appends a parameter (or parameters), ensuring that keys remain unique.
+// The receiver is not altered, so values that share parameters can be extended
+// independently.
 func (pp Parameters) Append(ps ...Parameter) Parameters {
+	result := make(Parameters, len(pp), len(pp)+len(ps))
+	copy(result, pp)
 	for _, p := range ps {
 		p.Key = strings.ToUpper(p.Key)
-		pp = append(pp.RemoveByKey(p.Key), p)
+		// the result is not shared, so it can be altered in place
+		kept := result[:0]
+		for _, q := range result {
+			if !strings.EqualFold(q.Key, p.Key) {
+				kept = append(kept, q)
+			}
+		}
+		result = append(kept, p)
 	}
-	return pp
+	return result
+}
+
+// Get finds the first parameter with a given key. The boolean result is false if
+// there is no such parameter.
+func (pp Parameters) Get(key string) (Parameter, bool) {
+	for _, p := range pp {
+		if strings.EqualFold(p.Key, key) {
+			return p, true
+		}
+	}
+	return Parameter{}, false
 }
@@ -0,0 +1,69 @@
+package parameter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse reads the parameters of a content line, as returned by ics.SplitContentLine.
+// The text is either blank or it starts with a semicolon. Parameter keys are converted
+// to upper case. Quoted values are unquoted and multiple values are split at commas.
+// RFC-6868 caret encoding is decoded.
+//
+// See https://tools.ietf.org/html/rfc5545#section-3.2
+func Parse(s string) (Parameters, error) {
+	var pp Parameters
+
+	for s != "" {
+		if s[0] != ';' {
+			return nil, fmt.Errorf("%q: expected ';'", s)
+		}
+		s = s[1:]
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("%q: parameter has no value", s)
+		}
+
+		key := strings.ToUpper(s[:eq])
+		s = s[eq+1:]
+
+		var values []string
+		for {
+			var v string
+			var err error
+			v, s, err = parseParamValue(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			values = append(values, caretDecode(v))
+
+			if s == "" || s[0] != comma {
+				break
+			}
+			s = s[1:]
+		}
+
+		pp = append(pp, Multiple(key, values...))
+	}
+
+	return pp, nil
+}
+
+// parseParamValue reads one (possibly quoted) parameter value from the start of s,
+// returning the value and the remaining text.
+func parseParamValue(s string) (string, string, error) {
+	if s != "" && s[0] == dquote {
+		end := strings.IndexByte(s[1:], dquote)
+		if end < 0 {
+			return "", "", fmt.Errorf("%q: unterminated quoted value", s)
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+
+	end := strings.IndexAny(s, ";,")
+	if end < 0 {
+		return s, "", nil
+	}
+	return s[:end], s[end:], nil
+}
@@ -0,0 +1,72 @@
+package parameter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		input string
+		exp   Parameters
+	}{
+		{"", nil},
+		{";cn=Joe", Parameters{CommonName("Joe")}},
+		{";ROLE=CHAIR;RSVP=TRUE", Parameters{Single("ROLE", "CHAIR"), Rsvp(true)}},
+		{`;CN="Blin, Ann"`, Parameters{CommonName("Blin, Ann")}},
+		{`;MEMBER="a,z","b",c`, Parameters{Member("a,z", "b", "c")}},
+		{`;ALTREP="cid:x;y"`, Parameters{AltRep("cid:x;y")}},
+	}
+
+	for i, c := range cases {
+		pp, err := Parse(c.input)
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if len(pp) != len(c.exp) {
+			t.Errorf("%d: expected %v but got %v", i, c.exp, pp)
+			continue
+		}
+		for j := range pp {
+			assertTrue(t, pp[j].Equals(c.exp[j]), "%d: expected %v but got %v", i, c.exp, pp)
+		}
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	cases := []string{
+		";CN=Joe",
+		`;MEMBER="a,z","b","c;u","d:1"`,
+		";DELEGATED-FROM=a,b",
+	}
+
+	for i, c := range cases {
+		pp, err := Parse(c)
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+
+		b := &bytes.Buffer{}
+		pp.WriteTo(b)
+		if s := b.String(); s != c {
+			t.Errorf("%d: expected %q but got %q", i, c, s)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{
+		"CN=Joe",
+		";CN",
+		`;CN="Joe`,
+	}
+
+	for i, c := range cases {
+		_, err := Parse(c)
+		if err == nil {
+			t.Errorf("%d: expected an error for %q", i, c)
+		}
+	}
+}
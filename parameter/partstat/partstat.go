@@ -42,3 +42,8 @@ func Delegated() parameter.Parameter {
 func Other(v string) parameter.Parameter {
 	return parameter.Single(PARTSTAT, v)
 }
+
+func init() {
+	parameter.Define(parameter.Definition{Key: PARTSTAT, Properties: []string{"ATTENDEE"},
+		Values: []string{"NEEDS-ACTION", "ACCEPTED", "DECLINED", "TENTATIVE", "DELEGATED", "COMPLETED", "IN-PROCESS"}, Extensible: true})
+}
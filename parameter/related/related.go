@@ -50,3 +50,11 @@ func Sibling() parameter.Parameter {
 func RelType(v string) parameter.Parameter {
 	return parameter.Single(RELTYPE, v)
 }
+
+func init() {
+	parameter.Define(
+		parameter.Definition{Key: RELATED, Properties: []string{"TRIGGER"}, Values: []string{"START", "END"}},
+		parameter.Definition{Key: RELTYPE, Properties: []string{"RELATED-TO"},
+			Values: []string{"PARENT", "CHILD", "SIBLING"}, Extensible: true},
+	)
+}
@@ -126,3 +126,28 @@ const TZID = "TZID"
 func TZid(v string) Parameter {
 	return Single("TZID", v)
 }
+
+var textProperties = []string{"COMMENT", "CONTACT", "DESCRIPTION", "LOCATION", "NAME", "RESOURCES", "SUMMARY"}
+
+var calendarUserProperties = []string{"ATTENDEE", "ORGANIZER"}
+
+var dateTimeProperties = []string{"DTSTART", "DTEND", "DUE", "EXDATE", "RDATE", "RECURRENCE-ID"}
+
+func init() {
+	Define(
+		Definition{Key: ALTREP, Properties: append(textProperties, "IMAGE")},
+		Definition{Key: CN, Properties: calendarUserProperties},
+		Definition{Key: DELEGATED_FROM, Properties: []string{"ATTENDEE"}},
+		Definition{Key: DELEGATED_TO, Properties: []string{"ATTENDEE"}},
+		Definition{Key: DIR, Properties: calendarUserProperties},
+		Definition{Key: ENCODING, Properties: []string{"ATTACH", "IMAGE"}, Values: []string{"8BIT", "BASE64"}},
+		Definition{Key: FMTTYPE, Properties: []string{"ATTACH", "IMAGE"}},
+		Definition{Key: LANGUAGE, Properties: append(textProperties,
+			"ATTENDEE", "CATEGORIES", "CONFERENCE", "ORGANIZER", "REQUEST-STATUS", "TZNAME")},
+		Definition{Key: MEMBER, Properties: []string{"ATTENDEE"}},
+		Definition{Key: RANGE, Properties: []string{"RECURRENCE-ID"}, Values: []string{"THISANDFUTURE"}},
+		Definition{Key: RSVP, Properties: []string{"ATTENDEE"}, Values: []string{"TRUE", "FALSE"}},
+		Definition{Key: SENT_BY, Properties: calendarUserProperties},
+		Definition{Key: TZID, Properties: dateTimeProperties},
+	)
+}
@@ -18,3 +18,10 @@ const LABEL = "LABEL"
 func Label(v string) Parameter {
 	return Single(LABEL, v)
 }
+
+func init() {
+	Define(
+		Definition{Key: EMAIL, Properties: calendarUserProperties},
+		Definition{Key: LABEL, Properties: []string{"CONFERENCE"}},
+	)
+}
@@ -36,3 +36,8 @@ func NonParticipant() parameter.Parameter {
 func Other(v string) parameter.Parameter {
 	return parameter.Single(ROLE, v)
 }
+
+func init() {
+	parameter.Define(parameter.Definition{Key: ROLE, Properties: []string{"ATTENDEE"},
+		Values: []string{"CHAIR", "REQ-PARTICIPANT", "OPT-PARTICIPANT", "NON-PARTICIPANT"}, Extensible: true})
+}
@@ -83,3 +83,8 @@ func UTCOffset() parameter.Parameter {
 func Type(v string) parameter.Parameter {
 	return parameter.Single(VALUE, v)
 }
+
+func init() {
+	parameter.Define(parameter.Definition{Key: VALUE, Values: []string{"BINARY", "BOOLEAN", "CAL-ADDRESS", "DATE", DATE_TIME,
+		"DURATION", "FLOAT", "INTEGER", "PERIOD", "RECUR", "TEXT", "TIME", "URI", "UTC-OFFSET"}, Extensible: true})
+}
@@ -0,0 +1,296 @@
+package ical2
+
+import (
+	"fmt"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/value"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Cardinality states how many times a property may occur in a component.
+type Cardinality int
+
+const (
+	// ZeroOrMore allows the property to be absent or repeated.
+	ZeroOrMore Cardinality = iota
+	// ZeroOrOne allows the property to be absent but not repeated.
+	ZeroOrOne
+	// ExactlyOne requires the property to be present once.
+	ExactlyOne
+	// OneOrMore requires the property to be present at least once.
+	OneOrMore
+)
+
+// PropertyDef declares an extension property.
+type PropertyDef struct {
+	// Name is the property name, e.g. "X-PUBLISHED-TTL".
+	Name string
+
+	// Type is the value type, as used in VALUE parameters, e.g. "DURATION" or "BOOLEAN".
+	Type string
+
+	// Components lists the names of the components that allow the property, e.g.
+	// "VCALENDAR" or "VEVENT". If it is empty, any component allows the property.
+	Components []string
+
+	// Cardinality applies in each component that allows the property.
+	Cardinality Cardinality
+}
+
+// PropertyRegistry holds declarations of extension properties. It is safe for
+// concurrent use.
+type PropertyRegistry struct {
+	mu     sync.RWMutex
+	defs   map[string]PropertyDef
+	strict bool
+}
+
+// Registry is the registry used when encoding and decoding. Declared extension
+// properties are validated when they are encoded and are decoded to their
+// declared type.
+var Registry = NewPropertyRegistry()
+
+// NewPropertyRegistry returns an empty registry.
+func NewPropertyRegistry() *PropertyRegistry {
+	return &PropertyRegistry{defs: make(map[string]PropertyDef)}
+}
+
+var (
+	ianaToken = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+	// the types that can be checked
+	valueTypes = map[string]bool{
+		"BINARY": true, "BOOLEAN": true, "CAL-ADDRESS": true, "DATE": true, "DATE-TIME": true,
+		"DURATION": true, "FLOAT": true, "INTEGER": true, "PERIOD": true, "RECUR": true,
+		"TEXT": true, "TIME": true, "URI": true, "UTC-OFFSET": true,
+	}
+)
+
+// Register declares extension properties, replacing any earlier declarations with
+// the same names. Each name must be an x-name or iana-token and each type must be
+// one of the RFC-5545 value types. If any declaration is invalid, none is registered.
+// The declarations are copied, so the caller's slices are not altered.
+func (r *PropertyRegistry) Register(defs ...PropertyDef) error {
+	checked := make([]PropertyDef, len(defs))
+	for i, def := range defs {
+		def.Name = strings.ToUpper(def.Name)
+		def.Type = strings.ToUpper(def.Type)
+
+		if err := checkPropertyName(def.Name); err != nil {
+			return err
+		}
+
+		if !valueTypes[def.Type] {
+			return fmt.Errorf("%s: unknown value type %q", def.Name, def.Type)
+		}
+
+		components := make([]string, len(def.Components))
+		for j, c := range def.Components {
+			components[j] = strings.ToUpper(c)
+		}
+		def.Components = components
+
+		checked[i] = def
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, def := range checked {
+		r.defs[def.Name] = def
+	}
+
+	return nil
+}
+
+// SetStrict sets whether extension properties that have not been declared are
+// rejected. By default, they are allowed.
+func (r *PropertyRegistry) SetStrict(strict bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strict = strict
+}
+
+// Lookup finds the declaration of a property. The boolean result is false if
+// there is none.
+func (r *PropertyRegistry) Lookup(name string) (PropertyDef, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	def, exists := r.defs[strings.ToUpper(name)]
+	return def, exists
+}
+
+// Validate checks the extensions of a component, such as "VEVENT". Every key must
+// be a valid property name. Declared properties must be allowed in the component,
+// must have values of the declared type and must occur the declared number of times.
+func (r *PropertyRegistry) Validate(component string, extensions []Extension) error {
+	r.mu.RLock()
+	strict := r.strict
+	r.mu.RUnlock()
+
+	counts := make(map[string]int)
+
+	for _, x := range extensions {
+		name := strings.ToUpper(x.Key)
+		if err := checkPropertyName(name); err != nil {
+			return fmt.Errorf("%s: %w", component, err)
+		}
+
+		def, exists := r.Lookup(name)
+		if !exists {
+			if strict {
+				return fmt.Errorf("%s: %s has not been registered", component, x.Key)
+			}
+			continue
+		}
+
+		if !def.allowedIn(component) {
+			return fmt.Errorf("%s: %s is not allowed", component, x.Key)
+		}
+
+		if err := checkValueType(def.Type, x.Value); err != nil {
+			return fmt.Errorf("%s: %s: %w", component, x.Key, err)
+		}
+
+		counts[name]++
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for name, def := range r.defs {
+		if !def.allowedIn(component) {
+			continue
+		}
+
+		switch n := counts[name]; {
+		case n > 1 && (def.Cardinality == ZeroOrOne || def.Cardinality == ExactlyOne):
+			return fmt.Errorf("%s: %s may occur only once but occurs %d times", component, name, n)
+		case n == 0 && (def.Cardinality == ExactlyOne || def.Cardinality == OneOrMore):
+			return fmt.Errorf("%s: %s is required", component, name)
+		}
+	}
+
+	return nil
+}
+
+func (def PropertyDef) allowedIn(component string) bool {
+	if len(def.Components) == 0 {
+		return true
+	}
+	for _, c := range def.Components {
+		if strings.EqualFold(c, component) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkPropertyName(name string) error {
+	if !ianaToken.MatchString(name) {
+		return fmt.Errorf("%q is not a valid property name", name)
+	}
+	return nil
+}
+
+// checkValueType checks that a value has the Go type corresponding to a value type,
+// or is text that can be parsed as that type.
+func checkValueType(vt string, v ics.Valuer) error {
+	var s string
+	switch x := v.(type) {
+	case value.RawValue:
+		s = x.Value
+	case value.TextValue:
+		if vt == "TEXT" {
+			return nil
+		}
+		s = x.Value
+	case value.ListValue:
+		return matchType(vt, "TEXT")
+	case value.URIValue:
+		if vt == "CAL-ADDRESS" {
+			return nil
+		}
+		return matchType(vt, "URI")
+	case value.DurationValue:
+		return matchType(vt, "DURATION")
+	case value.IntegerValue:
+		return matchType(vt, "INTEGER")
+	case value.DateTimeValue:
+		if vt == "DATE" {
+			return nil
+		}
+		return matchType(vt, "DATE-TIME")
+	case value.PeriodValue:
+		return matchType(vt, "PERIOD")
+	case value.GeoValue:
+		return matchType(vt, "FLOAT")
+	case value.BinaryValue:
+		return matchType(vt, "BINARY")
+	case value.RecurrenceValue:
+		return matchType(vt, "RECUR")
+	default:
+		return nil // not known, so cannot be checked
+	}
+
+	if _, err := parseTyped(vt, nil, s); err != nil {
+		return fmt.Errorf("%q is not a valid %s", s, vt)
+	}
+	return nil
+}
+
+func matchType(expected, actual string) error {
+	if expected != actual {
+		return fmt.Errorf("expected %s but got %s", expected, actual)
+	}
+	return nil
+}
+
+// parseTyped parses a value of a given type. Types without a corresponding
+// value type are checked and returned as raw values.
+func parseTyped(vt string, params parameter.Parameters, s string) (ics.Valuer, error) {
+	switch vt {
+	case "TEXT":
+		return value.ParseText(params, s), nil
+	case "URI", "CAL-ADDRESS":
+		return value.ParseURI(params, s), nil
+	case "DURATION":
+		return value.ParseDuration(params, s)
+	case "INTEGER":
+		return value.ParseInteger(params, s)
+	case "DATE", "DATE-TIME":
+		return value.ParseDateTime(params, s)
+	case "PERIOD":
+		return value.ParsePeriod(params, s)
+	case "RECUR":
+		return value.ParseRecurrence(params, s)
+	case "BINARY":
+		return value.ParseBinary(params, s)
+	case "BOOLEAN":
+		if !strings.EqualFold(s, "TRUE") && !strings.EqualFold(s, "FALSE") {
+			return nil, fmt.Errorf("%q is not a boolean", s)
+		}
+	case "FLOAT":
+		for _, f := range strings.Split(s, ";") {
+			if _, err := strconv.ParseFloat(f, 64); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value.ParseRaw(params, s), nil
+}
+
+// parseExtension decodes an extension property, using its declared type if it
+// has one. Values that do not match the declared type are kept as raw values.
+func (r *PropertyRegistry) parseExtension(p rawProperty) Extension {
+	if def, exists := r.Lookup(p.Name); exists {
+		if v, err := parseTyped(def.Type, p.Parameters, p.Value); err == nil {
+			return Extension{Key: p.Name, Value: v}
+		}
+	}
+	return Extension{Key: p.Name, Value: value.ParseRaw(p.Parameters, p.Value)}
+}
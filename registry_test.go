@@ -0,0 +1,124 @@
+package ical2_test
+
+import (
+	"bytes"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRegistry(t *testing.T) *ical2.PropertyRegistry {
+	t.Helper()
+	r := ical2.NewPropertyRegistry()
+	err := r.Register(
+		ical2.PropertyDef{Name: "X-PUBLISHED-TTL", Type: "DURATION", Components: []string{"VCALENDAR"}, Cardinality: ical2.ZeroOrOne},
+		ical2.PropertyDef{Name: "x-apple-travel-advisory", Type: "boolean", Components: []string{"VEVENT", "VTODO"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRegistryValidate(t *testing.T) {
+	r := testRegistry(t)
+
+	cases := []struct {
+		component  string
+		extensions []ical2.Extension
+		err        string
+	}{
+		{"VCALENDAR", []ical2.Extension{{"X-PUBLISHED-TTL", value.Duration("PT1H")}}, ""},
+		{"VCALENDAR", []ical2.Extension{{"X-PUBLISHED-TTL", value.Raw("PT1H")}}, ""},
+		{"VCALENDAR", []ical2.Extension{{"X-OTHER", value.Text("x")}}, ""},
+		{"VEVENT", []ical2.Extension{{"X-APPLE-TRAVEL-ADVISORY", value.Raw("true")}}, ""},
+		{"VCALENDAR", []ical2.Extension{{"X-PUBLISHED-TTL", value.Integer(3600)}}, "VCALENDAR: X-PUBLISHED-TTL: expected DURATION but got INTEGER"},
+		{"VCALENDAR", []ical2.Extension{{"X-PUBLISHED-TTL", value.Text("hourly")}}, `VCALENDAR: X-PUBLISHED-TTL: "hourly" is not a valid DURATION`},
+		{"VCALENDAR", []ical2.Extension{{"X-PUBLISHED-TTL", value.Duration("PT1H")}, {"X-PUBLISHED-TTL", value.Duration("PT2H")}},
+			"VCALENDAR: X-PUBLISHED-TTL may occur only once but occurs 2 times"},
+		{"VEVENT", []ical2.Extension{{"X-PUBLISHED-TTL", value.Duration("PT1H")}}, "VEVENT: X-PUBLISHED-TTL is not allowed"},
+		{"VEVENT", []ical2.Extension{{"X-APPLE-TRAVEL-ADVISORY", value.Raw("maybe")}}, `VEVENT: X-APPLE-TRAVEL-ADVISORY: "maybe" is not a valid BOOLEAN`},
+		{"VEVENT", []ical2.Extension{{"X BAD", value.Text("x")}}, `VEVENT: "X BAD" is not a valid property name`},
+	}
+
+	for i, c := range cases {
+		err := r.Validate(c.component, c.extensions)
+		if (err == nil && c.err != "") || (err != nil && err.Error() != c.err) {
+			t.Errorf("%d: expected %q but got %v", i, c.err, err)
+		}
+	}
+
+	r.SetStrict(true)
+	if err := r.Validate("VCALENDAR", []ical2.Extension{{"X-OTHER", value.Text("x")}}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestRegistryRequired(t *testing.T) {
+	r := ical2.NewPropertyRegistry()
+	r.Register(ical2.PropertyDef{Name: "X-WR-CALNAME", Type: "TEXT", Components: []string{"VCALENDAR"}, Cardinality: ical2.ExactlyOne})
+
+	if err := r.Validate("VCALENDAR", nil); err == nil || err.Error() != "VCALENDAR: X-WR-CALNAME is required" {
+		t.Errorf("got %v", err)
+	}
+
+	if err := r.Validate("VEVENT", nil); err != nil {
+		t.Errorf("got %v", err)
+	}
+
+	if err := r.Register(ical2.PropertyDef{Name: "X-FOO", Type: "STRING"}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestRegistryRegisterIsAtomic(t *testing.T) {
+	r := ical2.NewPropertyRegistry()
+	components := []string{"vevent"}
+
+	err := r.Register(
+		ical2.PropertyDef{Name: "X-GOOD", Type: "TEXT", Components: components},
+		ical2.PropertyDef{Name: "X-BAD", Type: "STRING"},
+	)
+	if err == nil {
+		t.Errorf("expected an error")
+	}
+	if _, exists := r.Lookup("X-GOOD"); exists {
+		t.Errorf("X-GOOD should not have been registered")
+	}
+
+	if err := r.Register(ical2.PropertyDef{Name: "X-GOOD", Type: "TEXT", Components: components}); err != nil {
+		t.Fatal(err)
+	}
+	if def, _ := r.Lookup("X-GOOD"); components[0] != "vevent" || def.Components[0] != "VEVENT" {
+		t.Errorf("got %v %v", components, def.Components)
+	}
+}
+
+func TestRegistryEncodeDecode(t *testing.T) {
+	saved := ical2.Registry
+	defer func() { ical2.Registry = saved }()
+	ical2.Registry = testRegistry(t)
+
+	dt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	event := (&ical2.VEvent{UID: value.Text("1"), DTStamp: value.TStamp(dt), Start: value.DateTime(dt)}).
+		Extend("X-PUBLISHED-TTL", value.Duration("PT1H"))
+
+	c := ical2.NewVCalendar("-//Test//EN").With(event)
+	if err := c.Encode(&bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "X-PUBLISHED-TTL is not allowed") {
+		t.Errorf("got %v", err)
+	}
+
+	event.Extensions = nil
+	c.Extend("X-PUBLISHED-TTL", value.Raw("PT12H"))
+
+	decoded, err := ical2.Decode(strings.NewReader(c.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := decoded.Extensions[0].Value.(value.DurationValue); !ok {
+		t.Errorf("got %T", decoded.Extensions[0].Value)
+	}
+}
@@ -0,0 +1,271 @@
+package ical2
+
+import (
+	"github.com/rickb777/ical2/parameter"
+	"sort"
+	"strings"
+)
+
+// PropertyRule states how a property may be used in a component.
+type PropertyRule struct {
+	// Name is the property name, e.g. "DTSTART".
+	Name string
+
+	// Cardinality states how many times the property may occur.
+	Cardinality Cardinality
+
+	// Parameters lists the parameters that may be used with the property. VALUE,
+	// x-name and iana-token parameters are allowed on every property so are not listed.
+	Parameters []string
+}
+
+// ComponentSchema describes the properties and sub-components allowed in a
+// component, as given by RFC-5545, RFC-7986 and the VPATCH draft.
+type ComponentSchema struct {
+	// Name is the component name, e.g. "VEVENT".
+	Name string
+
+	// Action distinguishes the kinds of VALARM, e.g. "DISPLAY"; otherwise it is blank.
+	Action string
+
+	// Properties lists the allowed properties. Any x-name or iana-token property
+	// is also allowed.
+	Properties []PropertyRule
+
+	// Exclusive lists the pairs of properties that must not occur together.
+	Exclusive [][2]string
+
+	// Requires lists the pairs of properties in which, if the first occurs, the
+	// second must also occur.
+	Requires [][2]string
+
+	// Components lists the allowed sub-components.
+	Components []string
+}
+
+// Key gives the name by which the schema is found using Schema, e.g. "VEVENT" or
+// "VALARM/DISPLAY".
+func (s ComponentSchema) Key() string {
+	if s.Action != "" {
+		return s.Name + "/" + s.Action
+	}
+	return s.Name
+}
+
+// Property finds the rule for a property. The boolean result is false if the
+// property is not listed.
+func (s ComponentSchema) Property(name string) (PropertyRule, bool) {
+	for _, p := range s.Properties {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return PropertyRule{}, false
+}
+
+// Required lists the properties that must occur at least once.
+func (s ComponentSchema) Required() []string {
+	var names []string
+	for _, p := range s.Properties {
+		if p.Cardinality == ExactlyOne || p.Cardinality == OneOrMore {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// Schema finds the schema for a component. VALARM schemas depend on the action, so
+// the name has the form "VALARM/AUDIO". The boolean result is false if the
+// component is not known. The result must not be modified.
+func Schema(name string) (ComponentSchema, bool) {
+	s, exists := schemaIndex[strings.ToUpper(name)]
+	return s, exists
+}
+
+// Schemas lists the schemas of all the known components, ordered by key. The
+// result must not be modified.
+func Schemas() []ComponentSchema {
+	list := make([]ComponentSchema, 0, len(schemaIndex))
+	for _, s := range schemaIndex {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key() < list[j].Key() })
+	return list
+}
+
+// PropertyParameters lists the parameters that may be used with a property,
+// ordered by key. This excludes VALUE, x-name and iana-token parameters, which may
+// be used with all properties. The list is derived from the parameter definitions;
+// see parameter.Define.
+func PropertyParameters(name string) []string {
+	var keys []string
+	for _, def := range parameter.Definitions() {
+		if len(def.Properties) > 0 && def.AppliesTo(name) {
+			keys = append(keys, def.Key)
+		}
+	}
+	return keys
+}
+
+//-------------------------------------------------------------------------------------------------
+
+func properties(cardinality Cardinality, names ...string) []PropertyRule {
+	rules := make([]PropertyRule, len(names))
+	for i, name := range names {
+		rules[i] = PropertyRule{Name: name, Cardinality: cardinality, Parameters: PropertyParameters(name)}
+	}
+	return rules
+}
+
+func concat(lists ...[]PropertyRule) []PropertyRule {
+	var result []PropertyRule
+	for _, l := range lists {
+		result = append(result, l...)
+	}
+	return result
+}
+
+var alarmRequires = [][2]string{{"DURATION", "REPEAT"}, {"REPEAT", "DURATION"}}
+
+var schemaIndex = func() map[string]ComponentSchema {
+	schemas := []ComponentSchema{
+		{
+			Name: "VCALENDAR",
+			Properties: concat(
+				properties(ExactlyOne, "PRODID", "VERSION"),
+				properties(ZeroOrOne, "CALSCALE", "METHOD", "UID", "LAST-MODIFIED", "URL", "REFRESH-INTERVAL", "SOURCE", "COLOR"),
+				properties(ZeroOrMore, "NAME", "DESCRIPTION", "CATEGORIES", "IMAGE"),
+			),
+			Components: []string{"VEVENT", "VTODO", "VJOURNAL", "VFREEBUSY", "VTIMEZONE", "VPATCH"},
+		},
+		{
+			Name: "VEVENT",
+			Properties: concat(
+				properties(ExactlyOne, "DTSTAMP", "UID"),
+				properties(ZeroOrOne, "DTSTART", "CLASS", "CREATED", "DESCRIPTION", "GEO", "LAST-MODIFIED", "LOCATION",
+					"ORGANIZER", "PRIORITY", "SEQUENCE", "STATUS", "SUMMARY", "TRANSP", "URL", "RECURRENCE-ID", "RRULE",
+					"DTEND", "DURATION", "COLOR"),
+				properties(ZeroOrMore, "ATTACH", "ATTENDEE", "CATEGORIES", "COMMENT", "CONTACT", "EXDATE",
+					"REQUEST-STATUS", "RELATED-TO", "RESOURCES", "RDATE", "CONFERENCE", "IMAGE"),
+			),
+			Exclusive:  [][2]string{{"DTEND", "DURATION"}},
+			Components: []string{"VALARM"},
+		},
+		{
+			Name: "VTODO",
+			Properties: concat(
+				properties(ExactlyOne, "DTSTAMP", "UID"),
+				properties(ZeroOrOne, "CLASS", "COMPLETED", "CREATED", "DESCRIPTION", "DTSTART", "GEO", "LAST-MODIFIED",
+					"LOCATION", "ORGANIZER", "PERCENT-COMPLETE", "PRIORITY", "RECURRENCE-ID", "SEQUENCE", "STATUS",
+					"SUMMARY", "URL", "RRULE", "DUE", "DURATION", "COLOR"),
+				properties(ZeroOrMore, "ATTACH", "ATTENDEE", "CATEGORIES", "COMMENT", "CONTACT", "EXDATE",
+					"REQUEST-STATUS", "RELATED-TO", "RESOURCES", "RDATE", "CONFERENCE", "IMAGE"),
+			),
+			Exclusive:  [][2]string{{"DUE", "DURATION"}},
+			Requires:   [][2]string{{"DURATION", "DTSTART"}},
+			Components: []string{"VALARM"},
+		},
+		{
+			Name: "VJOURNAL",
+			Properties: concat(
+				properties(ExactlyOne, "DTSTAMP", "UID"),
+				properties(ZeroOrOne, "CLASS", "CREATED", "DTSTART", "LAST-MODIFIED", "ORGANIZER", "RECURRENCE-ID",
+					"SEQUENCE", "STATUS", "SUMMARY", "URL", "RRULE", "COLOR"),
+				properties(ZeroOrMore, "ATTACH", "ATTENDEE", "CATEGORIES", "COMMENT", "CONTACT", "DESCRIPTION",
+					"EXDATE", "RELATED-TO", "RDATE", "REQUEST-STATUS", "IMAGE"),
+			),
+		},
+		{
+			Name: "VFREEBUSY",
+			Properties: concat(
+				properties(ExactlyOne, "DTSTAMP", "UID"),
+				properties(ZeroOrOne, "CONTACT", "DTSTART", "DTEND", "ORGANIZER", "URL"),
+				properties(ZeroOrMore, "ATTENDEE", "COMMENT", "FREEBUSY", "REQUEST-STATUS"),
+			),
+		},
+		{
+			Name: "VTIMEZONE",
+			Properties: concat(
+				properties(ExactlyOne, "TZID"),
+				properties(ZeroOrOne, "LAST-MODIFIED", "TZURL"),
+			),
+			Components: []string{"STANDARD", "DAYLIGHT"},
+		},
+		{
+			Name: "STANDARD",
+			Properties: concat(
+				properties(ExactlyOne, "DTSTART", "TZOFFSETTO", "TZOFFSETFROM"),
+				properties(ZeroOrOne, "RRULE"),
+				properties(ZeroOrMore, "COMMENT", "RDATE", "TZNAME"),
+			),
+		},
+		{
+			Name: "DAYLIGHT",
+			Properties: concat(
+				properties(ExactlyOne, "DTSTART", "TZOFFSETTO", "TZOFFSETFROM"),
+				properties(ZeroOrOne, "RRULE"),
+				properties(ZeroOrMore, "COMMENT", "RDATE", "TZNAME"),
+			),
+		},
+		{
+			Name:       "VPATCH",
+			Properties: properties(ExactlyOne, "DTSTAMP", "UID"),
+			Components: []string{"PATCH"},
+		},
+		{
+			Name: "PATCH",
+			Properties: concat(
+				properties(ExactlyOne, "PATCH-TARGET"),
+				properties(ZeroOrMore, "PATCH-DELETE"),
+			),
+		},
+		{
+			Name:   "VALARM",
+			Action: "AUDIO",
+			Properties: concat(
+				properties(ExactlyOne, "ACTION", "TRIGGER"),
+				properties(ZeroOrOne, "DURATION", "REPEAT", "ATTACH"),
+			),
+			Requires: alarmRequires,
+		},
+		{
+			Name:   "VALARM",
+			Action: "DISPLAY",
+			Properties: concat(
+				properties(ExactlyOne, "ACTION", "DESCRIPTION", "TRIGGER"),
+				properties(ZeroOrOne, "DURATION", "REPEAT"),
+			),
+			Requires: alarmRequires,
+		},
+		{
+			Name:   "VALARM",
+			Action: "EMAIL",
+			Properties: concat(
+				properties(ExactlyOne, "ACTION", "DESCRIPTION", "TRIGGER", "SUMMARY"),
+				properties(OneOrMore, "ATTENDEE"),
+				properties(ZeroOrOne, "DURATION", "REPEAT"),
+				properties(ZeroOrMore, "ATTACH"),
+			),
+			Requires: alarmRequires,
+		},
+	}
+
+	index := make(map[string]ComponentSchema, len(schemas))
+	for _, s := range schemas {
+		index[s.Key()] = s
+	}
+	return index
+}()
+
+// definedProperties holds the names of the properties that are listed in any schema.
+// The parameters of other properties, which are x-names or unregistered iana-tokens,
+// are not checked.
+var definedProperties = func() map[string]bool {
+	names := make(map[string]bool)
+	for _, s := range schemaIndex {
+		for _, p := range s.Properties {
+			names[p.Name] = true
+		}
+	}
+	return names
+}()
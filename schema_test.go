@@ -0,0 +1,78 @@
+package ical2_test
+
+import (
+	"fmt"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/value"
+	"testing"
+)
+
+func ExampleSchema() {
+	s, _ := ical2.Schema("VALARM/EMAIL")
+	fmt.Println(s.Required())
+
+	p, _ := s.Property("attendee")
+	fmt.Println(p.Cardinality == ical2.OneOrMore, p.Parameters[:4])
+
+	// Output:
+	// [ACTION DESCRIPTION TRIGGER SUMMARY ATTENDEE]
+	// true [CN CUTYPE DELEGATED-FROM DELEGATED-TO]
+}
+
+func TestSchemas(t *testing.T) {
+	var keys []string
+	for _, s := range ical2.Schemas() {
+		keys = append(keys, s.Key())
+	}
+
+	if fmt.Sprint(keys) != "[DAYLIGHT PATCH STANDARD VALARM/AUDIO VALARM/DISPLAY VALARM/EMAIL VCALENDAR VEVENT VFREEBUSY VJOURNAL VPATCH VTIMEZONE VTODO]" {
+		t.Errorf("got %v", keys)
+	}
+
+	s, exists := ical2.Schema("vevent")
+	if !exists || s.Exclusive[0] != [2]string{"DTEND", "DURATION"} || s.Components[0] != "VALARM" {
+		t.Errorf("got %+v", s)
+	}
+
+	if _, exists := s.Property("DUE"); exists {
+		t.Errorf("DUE is not allowed in VEVENT")
+	}
+
+	if p := ical2.PropertyParameters("dtstart"); fmt.Sprint(p) != "[TZID]" {
+		t.Errorf("got %v", p)
+	}
+}
+
+func TestSchemaValidatesComponent(t *testing.T) {
+	todo := ical2.NewComponent("VTODO").
+		Add("UID", value.Text("t1")).
+		Add("DUE", value.Raw("20240101")).
+		Add("DURATION", value.Duration("PT1H")).
+		Add("SUMMARY", value.Text("a")).
+		Add("SUMMARY", value.Text("b")).
+		Add("TRANSP", value.Text("OPAQUE"))
+
+	expected := []struct {
+		path     string
+		code     ical2.ErrorCode
+		severity ical2.Severity
+	}{
+		{"VTODO[uid=t1]/TRANSP", ical2.UnexpectedProperty, ical2.SeverityWarning},
+		{"VTODO[uid=t1]/DTSTAMP", ical2.MissingProperty, ical2.SeverityError},
+		{"VTODO[uid=t1]/SUMMARY", ical2.RepeatedProperty, ical2.SeverityError},
+		{"VTODO[uid=t1]/DURATION", ical2.ExclusiveProperties, ical2.SeverityError},
+		{"VTODO[uid=t1]/DURATION", ical2.DependentProperties, ical2.SeverityError},
+	}
+
+	problems := todo.Validate()
+	if len(problems) != len(expected) {
+		t.Fatalf("got %v", problems)
+	}
+
+	for i, exp := range expected {
+		p := problems[i]
+		if p.Path != exp.path || p.Code != exp.code || p.Severity != exp.severity {
+			t.Errorf("%d: got %s %v %v", i, p.Path, p.Code, p.Severity)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package ical2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"time"
+)
+
+// timeZonesOf finds the locations of the time zones defined by the VTIMEZONE
+// components of a calendar. TZIDs that are in the time zone database, or whose
+// X-LIC-LOCATION is, use the database. Otherwise the location is built from the
+// STANDARD and DAYLIGHT observances, provided that the current ones are fixed or
+// recur yearly on a given weekday of a given month, as most do. Time zones that
+// cannot be resolved are left out, so date-times that refer to them cannot be parsed.
+func timeZonesOf(raw *rawComponent) value.TimeZones {
+	var zz value.TimeZones
+	for _, sub := range raw.Components {
+		if sub.Name != "VTIMEZONE" {
+			continue
+		}
+
+		tzid := propertyValue(sub, "TZID")
+		if tzid == "" || tzid == "Local" {
+			continue
+		}
+		if _, err := time.LoadLocation(tzid); err == nil {
+			continue
+		}
+
+		var loc *time.Location
+		if lic := propertyValue(sub, "X-LIC-LOCATION"); lic != "" && lic != "Local" {
+			loc, _ = time.LoadLocation(lic)
+		}
+		if loc == nil {
+			loc = observedLocation(tzid, sub)
+		}
+
+		if loc != nil {
+			if zz == nil {
+				zz = make(value.TimeZones)
+			}
+			zz[tzid] = loc
+		}
+	}
+	return zz
+}
+
+// propertyValue gives the value of the first property with a name, or blank.
+func propertyValue(raw *rawComponent, name string) string {
+	for _, p := range raw.Properties {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// observance is a STANDARD or DAYLIGHT sub-component of a VTIMEZONE.
+type observance struct {
+	start  time.Time // local time, in the offset before the change
+	offset int       // seconds east of UTC, after the change
+	name   string
+	rule   value.RecurrenceValue
+}
+
+// observedLocation builds a location from the latest STANDARD and DAYLIGHT
+// observances, or returns nil if they cannot be expressed as a location.
+func observedLocation(tzid string, raw *rawComponent) *time.Location {
+	var std, dst *observance
+	for _, sub := range raw.Components {
+		o, err := observanceOf(sub)
+		if err != nil {
+			return nil
+		}
+
+		switch sub.Name {
+		case "STANDARD":
+			if std == nil || o.start.After(std.start) {
+				std = o
+			}
+		case "DAYLIGHT":
+			if dst == nil || o.start.After(dst.start) {
+				dst = o
+			}
+		}
+	}
+
+	switch {
+	case std == nil && dst == nil:
+		return nil
+	case std == nil:
+		return time.FixedZone(tzid, dst.offset)
+	case dst == nil || !ongoing(std) && !ongoing(dst):
+		// the latest change is permanent
+		if dst != nil && dst.start.After(std.start) {
+			return time.FixedZone(tzid, dst.offset)
+		}
+		return time.FixedZone(tzid, std.offset)
+	}
+
+	stdRule, ok1 := posixRule(std)
+	dstRule, ok2 := posixRule(dst)
+	if !ok1 || !ok2 {
+		return nil
+	}
+
+	tz := posixName(std) + posixOffset(-std.offset) + posixName(dst)
+	if dst.offset != std.offset+3600 {
+		tz += posixOffset(-dst.offset)
+	}
+	tz += "," + dstRule + "," + stdRule
+
+	loc, err := time.LoadLocationFromTZData(tzid, tzData(tz, std.offset, abbreviation(std)))
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+func observanceOf(raw *rawComponent) (*observance, error) {
+	o := &observance{}
+	for _, p := range raw.Properties {
+		var err error
+		switch p.Name {
+		case "DTSTART":
+			o.start, err = time.Parse(dateTimeLayout, p.Value)
+		case "TZOFFSETTO":
+			o.offset, err = parseUTCOffset(p.Value)
+		case "TZNAME":
+			o.name = p.Value
+		case "RRULE":
+			o.rule, err = value.ParseRecurrence(p.Parameters, p.Value)
+		}
+		if err != nil {
+			return nil, p.wrap(err)
+		}
+	}
+	return o, nil
+}
+
+const dateTimeLayout = "20060102T150405"
+
+// ongoing is true for observances that recur without end.
+func ongoing(o *observance) bool {
+	return o.rule.IsDefined() && o.rule.Until.IsZero() && o.rule.Count == 0
+}
+
+// posixRule expresses a yearly rule such as "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU" as a
+// POSIX TZ rule, e.g. "M3.5.0/1:00:00".
+func posixRule(o *observance) (string, bool) {
+	r := o.rule
+	if !ongoing(o) || r.Freq != "YEARLY" || r.Interval > 1 || len(r.ByMonth) != 1 || len(r.ByDay) != 1 ||
+		len(r.ByMonthDay) > 0 || len(r.ByYearDay) > 0 || len(r.ByWeekNo) > 0 || len(r.BySetPos) > 0 {
+		return "", false
+	}
+
+	week := r.ByDay[0].OrdWk
+	switch {
+	case week == -1:
+		week = 5
+	case week < 1 || week > 4:
+		return "", false
+	}
+
+	h, m, s := o.start.Clock()
+	return fmt.Sprintf("M%d.%d.%d/%d:%02d:%02d", r.ByMonth[0], week, r.ByDay[0].WeekDay, h, m, s), true
+}
+
+// posixName gives the observance's TZNAME if POSIX allows it, or otherwise a
+// quoted name based on its offset, e.g. "<+0530>".
+func posixName(o *observance) string {
+	if len(o.name) >= 3 && strings.Trim(o.name, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz") == "" {
+		return o.name
+	}
+	return "<" + abbreviation(o) + ">"
+}
+
+func abbreviation(o *observance) string {
+	if o.name != "" && strings.Trim(o.name, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+-") == "" {
+		return o.name
+	}
+	sign, offset := '+', o.offset
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	if offset%3600 == 0 {
+		return fmt.Sprintf("%c%02d", sign, offset/3600)
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset%3600/60)
+}
+
+// posixOffset formats seconds west of UTC, as POSIX requires.
+func posixOffset(seconds int) string {
+	sign := ""
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	return fmt.Sprintf("%s%d:%02d:%02d", sign, seconds/3600, seconds%3600/60, seconds%60)
+}
+
+// parseUTCOffset reads a UTC offset, e.g. "-0500" or "+053000".
+// See https://tools.ietf.org/html/rfc5545#section-3.3.14
+func parseUTCOffset(s string) (int, error) {
+	if (len(s) != 5 && len(s) != 7) || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("%q is not a valid UTC offset", s)
+	}
+
+	n := 0
+	for i := 1; i < len(s); i += 2 {
+		if s[i] < '0' || s[i] > '9' || s[i+1] < '0' || s[i+1] > '9' {
+			return 0, fmt.Errorf("%q is not a valid UTC offset", s)
+		}
+		d := int(s[i]-'0')*10 + int(s[i+1]-'0')
+		if d >= 60 && i > 1 {
+			return 0, fmt.Errorf("%q is not a valid UTC offset", s)
+		}
+		n = n*60 + d
+	}
+	if len(s) == 5 {
+		n *= 60
+	}
+
+	if s[0] == '-' {
+		n = -n
+	}
+	return n, nil
+}
+
+// tzData builds time zone data in TZif version 2 format (RFC-8536) that has no
+// transitions, so that the POSIX TZ rule in its footer applies at all times.
+func tzData(rule string, offset int, abbr string) []byte {
+	var b []byte
+	for range 2 {
+		// the version 1 header and data, then the same for version 2
+		b = append(b, "TZif2"...)
+		b = append(b, make([]byte, 15)...)
+		for _, n := range []int{0, 0, 0, 0, 1, len(abbr) + 1} {
+			b = binary.BigEndian.AppendUint32(b, uint32(n))
+		}
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(offset)))
+		b = append(b, 0, 0)
+		b = append(b, abbr...)
+		b = append(b, 0)
+	}
+	b = append(b, '\n')
+	b = append(b, rule...)
+	return append(b, '\n')
+}
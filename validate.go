@@ -0,0 +1,367 @@
+package ical2
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
+	_ "github.com/rickb777/ical2/parameter/cutype" // these parameters define themselves
+	_ "github.com/rickb777/ical2/parameter/display"
+	_ "github.com/rickb777/ical2/parameter/feature"
+	_ "github.com/rickb777/ical2/parameter/freebusy"
+	_ "github.com/rickb777/ical2/parameter/partstat"
+	_ "github.com/rickb777/ical2/parameter/related"
+	_ "github.com/rickb777/ical2/parameter/role"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"sync"
+)
+
+// Severity grades a validation problem.
+type Severity int
+
+const (
+	// SeverityError marks data that is invalid; it will not be encoded.
+	SeverityError Severity = iota
+	// SeverityWarning marks data that is valid but probably not what was intended.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// ErrorCode classifies a validation problem.
+type ErrorCode int
+
+const (
+	// MissingProperty is a required property that is absent.
+	MissingProperty ErrorCode = iota + 1
+	// ExclusiveProperties are properties that must not occur together.
+	ExclusiveProperties
+	// DependentProperties are properties that must occur together.
+	DependentProperties
+	// InvalidValue is a property value that is malformed or out of range.
+	InvalidValue
+	// InconsistentValues are property values that contradict each other.
+	InconsistentValues
+	// InvalidExtension is an extension property rejected by the Registry.
+	InvalidExtension
+	// InvalidName is a component or property name that is missing or malformed.
+	InvalidName
+	// RepeatedProperty is a property that occurs more often than is allowed.
+	RepeatedProperty
+	// UnexpectedProperty is a property that is not allowed in its component.
+	UnexpectedProperty
+	// InvalidParameter is a parameter that is not applicable to its property or
+	// that has a value that is not allowed.
+	InvalidParameter
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	MissingProperty:     "missing property",
+	ExclusiveProperties: "exclusive properties",
+	DependentProperties: "dependent properties",
+	InvalidValue:        "invalid value",
+	InconsistentValues:  "inconsistent values",
+	InvalidExtension:    "invalid extension",
+	InvalidName:         "invalid name",
+	RepeatedProperty:    "repeated property",
+	UnexpectedProperty:  "unexpected property",
+	InvalidParameter:    "invalid parameter",
+}
+
+func (c ErrorCode) String() string {
+	if s, exists := errorCodeNames[c]; exists {
+		return s
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(c))
+}
+
+// Problem is one validation problem.
+type Problem struct {
+	// Path locates the problem, e.g. "VEVENT[uid=123]/VALARM[0]/TRIGGER". Components
+	// are identified by their UID if they have one, or otherwise by their position.
+	Path     string
+	Code     ErrorCode
+	Severity Severity
+	Message  string
+}
+
+func (p Problem) Error() string {
+	return p.Path + ": " + p.Message
+}
+
+// Problems lists the validation problems found in a calendar or component. As an
+// error, it reports all of them.
+type Problems []Problem
+
+func (ps Problems) Error() string {
+	s := make([]string, len(ps))
+	for i, p := range ps {
+		s[i] = p.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Errors returns only the problems that have SeverityError.
+func (ps Problems) Errors() Problems {
+	return ps.only(SeverityError)
+}
+
+// Warnings returns only the problems that have SeverityWarning.
+func (ps Problems) Warnings() Problems {
+	return ps.only(SeverityWarning)
+}
+
+// Err returns nil if there are no problems with SeverityError, or otherwise
+// returns those problems.
+func (ps Problems) Err() error {
+	if errs := ps.Errors(); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (ps Problems) only(severity Severity) Problems {
+	var result Problems
+	for _, p := range ps {
+		if p.Severity == severity {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// validator is implemented by the components that can validate themselves.
+// The parent is the path of the enclosing component followed by "/", or blank.
+// The index is the position in the parent, used when there is no UID.
+type validator interface {
+	validate(parent string, index int, method value.TextValue) Problems
+}
+
+// Validate checks the calendar and all its components, returning every problem
+// found. Encode refuses to write calendars that have problems with SeverityError.
+func (c *VCalendar) Validate() Problems {
+	v := &validation{name: "VCALENDAR", index: -1}
+
+	v.schema("VCALENDAR", newCounter().
+		add("PRODID", &c.ProdId).
+		add("VERSION", &c.Version).
+		add("CALSCALE", &c.CalScale).
+		add("METHOD", &c.Method).
+		add("NAME", &c.Name).
+		add("DESCRIPTION", &c.Description).
+		add("URL", &c.URL).
+		add("SOURCE", &c.Source).
+		add("LAST-MODIFIED", &c.LastModified).
+		add("REFRESH-INTERVAL", &c.RefreshInterval).
+		add("COLOR", &c.Color))
+	v.values("REFRESH-INTERVAL", &c.RefreshInterval)
+	v.extensions(c.Extensions)
+
+	for i, component := range c.VComponent {
+		v.child("", component, i, c.Method)
+	}
+
+	return v.problems
+}
+
+// validation accumulates the problems of one component.
+type validation struct {
+	parent   string
+	name     string
+	uid      value.TextValue
+	index    int // used when there is no UID; negative for none
+	problems Problems
+}
+
+func (v *validation) path() string {
+	switch {
+	case ics.IsDefined(v.uid):
+		return fmt.Sprintf("%s%s[uid=%s]", v.parent, v.name, v.uid.Value)
+	case v.index >= 0:
+		return fmt.Sprintf("%s%s[%d]", v.parent, v.name, v.index)
+	}
+	return v.parent + v.name
+}
+
+func (v *validation) add(property string, code ErrorCode, severity Severity, format string, args ...interface{}) {
+	path := v.path()
+	if property != "" {
+		path += "/" + property
+	}
+	v.problems = append(v.problems, Problem{Path: path, Code: code, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validation) required(defined bool, property string) {
+	if !defined {
+		v.add(property, MissingProperty, SeverityError, "%s is required", property)
+	}
+}
+
+func (v *validation) exclusive(a bool, aName string, b bool, bName string) {
+	if a && b {
+		v.add(bName, ExclusiveProperties, SeverityError, "%s and %s are exclusive; only one can be set", aName, bName)
+	}
+}
+
+// schema checks the number of occurrences of each property against the schema
+// with a given key, if there is one. It also checks the parameters of every property.
+// The counter is released afterwards, so it must not be used again.
+func (v *validation) schema(key string, c *counter) {
+	defer c.release()
+
+	v.parameters(c.values)
+
+	schema, exists := Schema(key)
+	if !exists {
+		return
+	}
+
+	counts := c.counts
+
+	for _, p := range schema.Properties {
+		switch n := counts[p.Name]; {
+		case n == 0 && (p.Cardinality == ExactlyOne || p.Cardinality == OneOrMore):
+			v.required(false, p.Name)
+		case n > 1 && (p.Cardinality == ExactlyOne || p.Cardinality == ZeroOrOne):
+			v.add(p.Name, RepeatedProperty, SeverityError, "%s may occur only once but occurs %d times", p.Name, n)
+		}
+	}
+
+	for _, pair := range schema.Exclusive {
+		v.exclusive(counts[pair[0]] > 0, pair[0], counts[pair[1]] > 0, pair[1])
+	}
+
+	for _, pair := range schema.Requires {
+		if counts[pair[0]] > 0 && counts[pair[1]] == 0 {
+			v.add(pair[0], DependentProperties, SeverityError, "%s requires %s", pair[0], pair[1])
+		}
+	}
+}
+
+// parameters checks that the parameters of each property are applicable to it
+// and have allowed values. Unregistered values of extensible parameters are warnings.
+// Properties that no schema defines, such as X-ALT-DESC, are not checked, just as
+// extension properties are not.
+func (v *validation) parameters(properties []Extension) {
+	for _, p := range properties {
+		if !definedProperties[strings.ToUpper(p.Key)] {
+			continue
+		}
+		for _, param := range parametersOf(p.Value) {
+			switch err := param.Check(p.Key); {
+			case err == nil:
+			case errors.Is(err, parameter.ErrUnregisteredValue):
+				v.add(p.Key, InvalidParameter, SeverityWarning, "%v", err)
+			default:
+				v.add(p.Key, InvalidParameter, SeverityError, "%v", err)
+			}
+		}
+	}
+}
+
+// parameterised is implemented by values that hold parameters.
+type parameterised interface {
+	Params() parameter.Parameters
+}
+
+// parametersOf gets the parameters held by a value.
+func parametersOf(v ics.Valuer) parameter.Parameters {
+	if x, ok := v.(parameterised); ok {
+		return x.Params()
+	}
+	return nil
+}
+
+// values checks values that can validate themselves, such as RRULEs.
+func (v *validation) values(property string, values ...ics.Valuer) {
+	for _, x := range values {
+		if !ics.IsDefined(x) {
+			continue
+		}
+		if vx, ok := x.(interface{ Validate() error }); ok {
+			if err := vx.Validate(); err != nil {
+				v.add(property, InvalidValue, SeverityError, "%s: %v", property, err)
+			}
+		}
+	}
+}
+
+func (v *validation) extensions(extensions []Extension) {
+	if err := Registry.Validate(v.name, extensions); err != nil {
+		v.add("", InvalidExtension, SeverityError, "%s", strings.TrimPrefix(err.Error(), v.name+": "))
+	}
+}
+
+// child validates a nested component, if it is able to validate itself.
+func (v *validation) child(parent string, component VComponent, index int, method value.TextValue) {
+	if c, ok := component.(validator); ok {
+		v.problems = append(v.problems, c.validate(parent, index, method)...)
+	}
+}
+
+// within gives the parent path for nested components.
+func (v *validation) within() string {
+	return v.path() + "/"
+}
+
+// counter counts the occurrences of each property and keeps their values. Values
+// held in struct fields are added by pointer so that they are not copied onto the
+// heap. Counters are pooled because validation happens every time a calendar is
+// encoded.
+type counter struct {
+	counts map[string]int
+	values []Extension
+}
+
+var counterPool = sync.Pool{
+	New: func() any { return &counter{counts: make(map[string]int)} },
+}
+
+func newCounter() *counter {
+	return counterPool.Get().(*counter)
+}
+
+func (c *counter) release() {
+	clear(c.counts)
+	clear(c.values)
+	c.values = c.values[:0]
+	counterPool.Put(c)
+}
+
+// add counts the defined values.
+func (c *counter) add(name string, values ...ics.Valuer) *counter {
+	for _, x := range values {
+		if ics.IsDefined(x) {
+			c.counts[name]++
+			c.values = append(c.values, Extension{name, x})
+		}
+	}
+	return c
+}
+
+// countAll counts the defined values in a slice of values.
+func countAll[V any, P interface {
+	*V
+	ics.Valuer
+}](c *counter, name string, values []V) *counter {
+	for i := range values {
+		c.add(name, P(&values[i]))
+	}
+	return c
+}
+
+// countEach counts the defined values in a slice of interfaces, such as []value.Temporal.
+func countEach[V ics.Valuer](c *counter, name string, values []V) *counter {
+	for _, x := range values {
+		c.add(name, x)
+	}
+	return c
+}
@@ -0,0 +1,172 @@
+package ical2_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/parameter/partstat"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	dt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	rrule := value.Recurrence("MONTHLY")
+	rrule.ByMonth = []uint{13}
+
+	c := ical2.NewVCalendar("-//Test//EN").
+		With(&ical2.VEvent{Start: value.DateTime(dt)}).
+		With(&ical2.VEvent{
+			UID:            value.Text("123"),
+			DTStamp:        value.TStamp(dt),
+			Start:          value.DateTime(dt),
+			End:            value.DateTime(dt.Add(-time.Hour)),
+			RecurrenceRule: rrule,
+			Attendee:       []value.URIValue{value.CalAddress("a@example.com")},
+			Alarm: []ical2.VAlarm{
+				&ical2.VDisplayAlarm{Description: value.Text("x"), Trigger: value.Duration("-PT5M")},
+				&ical2.VAudioAlarm{Repeat: value.Integer(2)},
+			},
+		})
+
+	expected := []struct {
+		path     string
+		code     ical2.ErrorCode
+		severity ical2.Severity
+	}{
+		{"VEVENT[0]/DTSTAMP", ical2.MissingProperty, ical2.SeverityError},
+		{"VEVENT[0]/UID", ical2.MissingProperty, ical2.SeverityError},
+		{"VEVENT[uid=123]/DTEND", ical2.InconsistentValues, ical2.SeverityError},
+		{"VEVENT[uid=123]/RRULE", ical2.InvalidValue, ical2.SeverityError},
+		{"VEVENT[uid=123]/ORGANIZER", ical2.MissingProperty, ical2.SeverityWarning},
+		{"VEVENT[uid=123]/VALARM[1]/TRIGGER", ical2.MissingProperty, ical2.SeverityError},
+		{"VEVENT[uid=123]/VALARM[1]/REPEAT", ical2.DependentProperties, ical2.SeverityError},
+	}
+
+	problems := c.Validate()
+	if len(problems) != len(expected) {
+		t.Fatalf("got %v", problems)
+	}
+
+	for i, exp := range expected {
+		p := problems[i]
+		if p.Path != exp.path || p.Code != exp.code || p.Severity != exp.severity {
+			t.Errorf("%d: got %s %v %v", i, p.Path, p.Code, p.Severity)
+		}
+	}
+
+	if n := len(problems.Warnings()); n != 1 {
+		t.Errorf("got %d warnings", n)
+	}
+
+	err := c.Encode(&bytes.Buffer{})
+	var errs ical2.Problems
+	if !errors.As(err, &errs) || len(errs) != 6 {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestValidateWarningsOnly(t *testing.T) {
+	dt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	event := &ical2.VEvent{
+		UID:      value.Text("1"),
+		DTStamp:  value.TStamp(dt),
+		Start:    value.DateTime(dt),
+		Attendee: []value.URIValue{value.CalAddress("a@example.com")},
+	}
+
+	if p := event.Validate(); len(p) != 1 || p.Err() != nil {
+		t.Errorf("got %v", p)
+	}
+
+	if err := ical2.NewVCalendar("-//Test//EN").With(event).Encode(&bytes.Buffer{}); err != nil {
+		t.Errorf("got %v", err)
+	}
+
+	if p := (&ical2.VEmailAlarm{}).Validate(); p.Error() != "VALARM[0]/DESCRIPTION: DESCRIPTION is required; "+
+		"VALARM[0]/TRIGGER: TRIGGER is required; VALARM[0]/SUMMARY: SUMMARY is required; VALARM[0]/ATTENDEE: ATTENDEE is required" {
+		t.Errorf("got %v", p)
+	}
+}
+
+func TestValidateParameters(t *testing.T) {
+	dt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	event := &ical2.VEvent{
+		UID:       value.Text("1"),
+		DTStamp:   value.TStamp(dt),
+		Start:     value.DateTime(dt),
+		Summary:   value.Text("Meeting").With(parameter.Rsvp(true)),
+		Organizer: value.CalAddress("a@example.com").With(partstat.Other("bogus")),
+		Attendee: []value.URIValue{
+			value.CalAddress("b@example.com").With(partstat.Other("X-LATER")),
+			value.CalAddress("c@example.com").With(partstat.Other("LATER")),
+		},
+	}
+
+	expected := []struct {
+		path     string
+		severity ical2.Severity
+	}{
+		{"VEVENT[uid=1]/ORGANIZER", ical2.SeverityError},
+		{"VEVENT[uid=1]/SUMMARY", ical2.SeverityError},
+		{"VEVENT[uid=1]/ATTENDEE", ical2.SeverityWarning},
+	}
+
+	problems := event.Validate()
+	if len(problems) != len(expected) {
+		t.Fatalf("got %v", problems)
+	}
+
+	for i, exp := range expected {
+		p := problems[i]
+		if p.Path != exp.path || p.Code != ical2.InvalidParameter || p.Severity != exp.severity {
+			t.Errorf("%d: got %s %v %v", i, p.Path, p.Code, p.Severity)
+		}
+	}
+}
+
+func TestEncodeRejectsInjection(t *testing.T) {
+	dt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	event := &ical2.VEvent{
+		UID:      value.Text("1"),
+		DTStamp:  value.TStamp(dt),
+		Start:    value.DateTime(dt),
+		Attendee: []value.URIValue{value.CalAddress("a@example.com\r\nORGANIZER:mailto:evil@example.com")},
+	}
+
+	buf := &bytes.Buffer{}
+	err := ical2.NewVCalendar("-//Test//EN").With(event).Encode(buf)
+	if !errors.Is(err, ics.ErrUnsafe) || strings.Contains(buf.String(), "evil") {
+		t.Errorf("got %v\n%s", err, buf.String())
+	}
+
+	// no part of the failed property is written
+	if strings.Contains(buf.String(), "ATTENDEE") || !strings.HasSuffix(buf.String(), "\r\n") {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func TestValidateDateTimeZones(t *testing.T) {
+	dt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	event := &ical2.VEvent{
+		UID:           value.Text("1"),
+		DTStamp:       value.TStamp(dt),
+		Start:         value.Zoned(dt.In(berlin)),
+		ExceptionDate: []value.DateTimeValue{value.Zoned(dt.In(berlin), dt.AddDate(0, 0, 7))},
+	}
+
+	problems := event.Validate()
+	if len(problems) != 1 || problems[0].Path != "VEVENT[uid=1]/EXDATE" || problems[0].Code != ical2.InvalidValue {
+		t.Errorf("got %v", problems)
+	}
+}
@@ -2,11 +2,14 @@ package value
 
 import (
 	"encoding/base64"
+	"fmt"
 	"github.com/rickb777/date/v2/timespan"
 	"github.com/rickb777/ical2/ics"
 	"github.com/rickb777/ical2/parameter"
 	"github.com/rickb777/ical2/parameter/value"
+	"github.com/rickb777/period"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -50,12 +53,24 @@ type DateTimeValue struct {
 	Value       time.Time
 	Others      []time.Time
 	includeTime bool
-	zulu        bool
+	form        dateTimeForm
 }
 
+// dateTimeForm is one of the three forms of date-time in RFC-5545.
+type dateTimeForm uint8
+
+const (
+	// inferredForm is UTC when the time is UTC, and otherwise is steered by any TZID parameter.
+	inferredForm dateTimeForm = iota
+	floatingForm
+	utcForm
+	zonedForm
+)
+
 // DateTime constructs a new date-time value. If the time parameter(s) is UTC, it is
 // represented using the Zulu "Z" suffix. Otherwise, it is represented as a
 // "floating" local time; however the TZID parameter can be used to steer this.
+// Floating, UTC and Zoned state the form explicitly and are usually preferable.
 //
 // If more than one time value is provided, they are represented as a comma-separated
 // list.
@@ -70,6 +85,62 @@ func DateTime(t time.Time, others ...time.Time) DateTimeValue {
 	}
 }
 
+// Floating constructs a new date-time value that is a "floating" local time, i.e. it
+// has no time zone. The wall-clock time of t is used as it is, whatever its location.
+// Any TZID parameter is not written.
+//
+// If more than one time value is provided, they are represented as a comma-separated
+// list.
+//
+// The property has VALUE=DATE-TIME.
+func Floating(t time.Time, others ...time.Time) DateTimeValue {
+	v := DateTime(t, others...)
+	v.form = floatingForm
+	return v
+}
+
+// UTC constructs a new date-time value that is converted to UTC and represented using
+// the Zulu "Z" suffix. Any TZID parameter is not written.
+//
+// If more than one time value is provided, they are represented as a comma-separated
+// list.
+//
+// The property has VALUE=DATE-TIME.
+func UTC(t time.Time, others ...time.Time) DateTimeValue {
+	utc := make([]time.Time, len(others))
+	for i, o := range others {
+		utc[i] = o.UTC()
+	}
+	v := DateTime(t.UTC(), utc...)
+	v.form = utcForm
+	return v
+}
+
+// Zoned constructs a new date-time value in the time zone of t, which is referenced
+// by a TZID parameter holding the IANA name of t.Location(), e.g. "Europe/Paris".
+// The calendar should define the same TZID in a VTIMEZONE component. If t is UTC,
+// the value is the same as for UTC(t).
+//
+// The location must have a name; time.Local does not, so it is rejected when the
+// value is validated or written. The name is not checked against the time zone
+// database, because calendars can define their own time zones in VTIMEZONE
+// components; so a location made by time.FixedZone is accepted and its name is
+// used as the TZID. All the times must be in the same location.
+//
+// If more than one time value is provided, they are represented as a comma-separated
+// list.
+//
+// The property has VALUE=DATE-TIME.
+func Zoned(t time.Time, others ...time.Time) DateTimeValue {
+	if t.Location().String() == "UTC" {
+		return UTC(t, others...)
+	}
+	v := DateTime(t, others...)
+	v.Parameters = v.Parameters.Append(parameter.TZid(t.Location().String()))
+	v.form = zonedForm
+	return v
+}
+
 // Date constructs a new date value, i.e. without time.
 //
 // If more than one time value is provided, they are represented as a comma-separated
@@ -91,7 +162,7 @@ func TStamp(t time.Time) DateTimeValue {
 	return DateTimeValue{
 		Value:       t.UTC(),
 		includeTime: true,
-		zulu:        true,
+		form:        utcForm,
 	}
 }
 
@@ -102,6 +173,11 @@ func (v DateTimeValue) AsDate() DateTimeValue {
 	return v
 }
 
+// Params gets the parameters of the value.
+func (v DateTimeValue) Params() parameter.Parameters {
+	return v.Parameters
+}
+
 // IsDefined tests whether the value has been explicitly defined or is default.
 func (v DateTimeValue) IsDefined() bool {
 	return !v.Value.IsZero()
@@ -113,30 +189,63 @@ func (v DateTimeValue) With(params ...parameter.Parameter) DateTimeValue {
 	return v
 }
 
+// Validate checks that all the times are in the same location, because a date-time
+// list has only one time zone. Zoned values must also have a TZID other than "Local".
+// The TZID is not looked up in the time zone database, because it may be defined by
+// a VTIMEZONE component instead.
+func (v DateTimeValue) Validate() error {
+	zone := v.Value.Location().String()
+	for _, o := range v.Others {
+		if z := o.Location().String(); z != zone {
+			return fmt.Errorf("times are in different zones, %q and %q", zone, z)
+		}
+	}
+
+	if v.form == zonedForm && v.includeTime {
+		if p, _ := v.Parameters.Get(parameter.TZID); p.Value == "" || p.Value == "Local" {
+			return fmt.Errorf("TZID %q does not name a time zone", p.Value)
+		}
+	}
+	return nil
+}
+
 // WriteTo writes the value to the writer.
 // This is part of the Valuer interface.
+// Invalid values (see Validate) are rejected and nothing is written.
 func (v DateTimeValue) WriteTo(w ics.StringWriter) (err error) {
+	if err := v.Validate(); err != nil {
+		return err
+	}
+
 	format := dateLayout
 	if v.includeTime {
-		// when the date-time is UTC, remove the TZID parameter and add Zulu "Z" instead
-		if zone, _ := v.Value.Zone(); zone == "UTC" {
-			v.Parameters = v.Parameters.RemoveByKey(parameter.TZID, "DATE-TIME")
-			v.zulu = true
-		}
-
 		format = dateTimeLayout
 
-		if v.zulu {
+		form := v.form
+		if form == inferredForm {
+			// when the date-time is UTC, remove the TZID parameter and add Zulu "Z" instead
+			if zone, _ := v.Value.Zone(); zone == "UTC" {
+				form = utcForm
+			}
+		}
+
+		switch form {
+		case utcForm:
+			v.Parameters = v.Parameters.RemoveByKey(parameter.TZID)
 			format = dateTimeLayoutZ
+		case floatingForm:
+			v.Parameters = v.Parameters.RemoveByKey(parameter.TZID)
 		}
 	}
 
-	v.Parameters.WriteTo(w)
+	if err := v.Parameters.WriteTo(w); err != nil {
+		return err
+	}
 	w.WriteByte(':')
-	_, err = w.WriteString(v.Value.Format(format))
+	err = ics.WriteTime(w, v.Value, format)
 	for _, o := range v.Others {
 		w.WriteByte(',')
-		_, err = w.WriteString(o.Format(format))
+		err = ics.WriteTime(w, o, format)
 	}
 	return err
 }
@@ -171,6 +280,11 @@ func PeriodOf(t time.Time, d time.Duration) PeriodValue {
 	return Period(timespan.TimeSpanOf(t, d))
 }
 
+// Params gets the parameters of the value.
+func (v PeriodValue) Params() parameter.Parameters {
+	return v.Parameters
+}
+
 // IsDefined tests whether the value has been explicitly defined or is default.
 func (v PeriodValue) IsDefined() bool {
 	return !v.Value.Start().IsZero()
@@ -184,10 +298,14 @@ func (v PeriodValue) With(params ...parameter.Parameter) PeriodValue {
 
 // WriteTo writes the value to the writer.
 // This is part of the Valuer interface.
+// The period is written in UTC, so any TZID parameter is not written.
 func (v PeriodValue) WriteTo(w ics.StringWriter) error {
-	v.Parameters.WriteTo(w)
+	if err := v.Parameters.RemoveByKey(parameter.TZID).WriteTo(w); err != nil {
+		return err
+	}
 	w.WriteByte(':')
-	_, e := w.WriteString(v.Value.FormatRFC5545(true))
+	utc := timespan.TimeSpanOf(v.Value.Start().UTC(), v.Value.Duration())
+	_, e := w.WriteString(utc.FormatRFC5545(true))
 	return e
 }
 
@@ -196,36 +314,234 @@ func (v PeriodValue) IsTemporal() {}
 
 //-------------------------------------------------------------------------------------------------
 
-// DurationValue holds a time duration. This should be in ISO-8601 form
-// (https://en.wikipedia.org/wiki/ISO_8601#Durations);
-// see github.com/rickb777/period for a compatible duration API.
+// DurationValue holds a time duration in the form required by RFC-5545, e.g. "PT15M",
+// "-P1D" or "P1W". This is a restricted form of ISO-8601 duration
+// (https://en.wikipedia.org/wiki/ISO_8601#Durations) that has no years, months or
+// fractions; see github.com/rickb777/period for a compatible duration API.
+//
+// See https://tools.ietf.org/html/rfc5545#section-3.3.6
 type DurationValue struct {
 	baseValue
 }
 
-// Duration returns a new DurationValue. It has VALUE=DURATION.
+// Duration returns a new DurationValue. It has VALUE=DURATION. The string must be in
+// the form required by RFC-5545; otherwise the value is rejected when it is validated
+// or written. DurationOf and DurationOfPeriod construct values that are always valid.
 func Duration(d string) DurationValue {
 	return DurationValue{baseValue{
 		Parameters: parameter.Parameters{value.Duration()},
 		Value:      d,
+		format:     verbatim,
 	}}
 }
 
+// DurationOf returns a new DurationValue from a time.Duration. A time.Duration is an
+// exact length of time, so it is expressed in hours, minutes and seconds, e.g. 26
+// hours is "PT26H". Days and weeks are nominal lengths that vary across daylight
+// saving changes, so they are never used; see DurationOfPeriod for those. Fractions
+// of a second are discarded. It has VALUE=DURATION.
+func DurationOf(d time.Duration) DurationValue {
+	parts := durationParts{negative: d < 0}
+	if parts.negative {
+		d = -d
+	}
+
+	secs := int64(d / time.Second)
+	parts.hours, secs = secs/3600, secs%3600
+	parts.minutes, parts.seconds = secs/60, secs%60
+
+	return Duration(parts.String())
+}
+
+// DurationOfPeriod returns a new DurationValue from a period. Periods that have years
+// or months, or fractions, are rejected because RFC-5545 cannot express them. Days
+// and weeks are kept as nominal lengths; weeks are expressed as days when there are
+// other parts. It has VALUE=DURATION.
+func DurationOfPeriod(p period.Period) (DurationValue, error) {
+	parts, err := parseDurationParts(p.String())
+	if err != nil {
+		return DurationValue{}, err
+	}
+	return Duration(parts.normalise().String()), nil
+}
+
 // With appends parameters to the value.
 func (v DurationValue) With(params ...parameter.Parameter) DurationValue {
 	v.Parameters = v.Parameters.Append(params...)
 	return v
 }
 
+// Validate checks that the duration is in the form required by RFC-5545. Control
+// characters are reported using an error wrapping ics.ErrUnsafe.
+func (v DurationValue) Validate() error {
+	if err := checkVerbatim(v.Value, ics.RejectControls); err != nil {
+		return err
+	}
+	if !durationPattern.MatchString(v.Value) {
+		return fmt.Errorf("%q is not a valid duration", v.Value)
+	}
+	return nil
+}
+
 // WriteTo writes the value to the writer.
 // This is part of the Valuer interface.
+// Invalid durations are rejected and nothing is written.
 func (v DurationValue) WriteTo(w ics.StringWriter) error {
-	v.Parameters.WriteTo(w)
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	if err := v.Parameters.WriteTo(w); err != nil {
+		return err
+	}
 	w.WriteByte(':')
 	_, e := w.WriteString(v.Value)
 	return e
 }
 
+// Duration converts the value to a time.Duration. Days are treated as 24 hours and
+// weeks as 7 days, even though RFC-5545 defines them as nominal durations that vary
+// across daylight-saving changes.
+func (v DurationValue) Duration() (time.Duration, error) {
+	if err := v.Validate(); err != nil {
+		return 0, err
+	}
+
+	parts, err := parseDurationParts(v.Value)
+	if err != nil {
+		return 0, err
+	}
+
+	d := time.Duration(parts.weeks*7+parts.days)*24*time.Hour +
+		time.Duration(parts.hours)*time.Hour +
+		time.Duration(parts.minutes)*time.Minute +
+		time.Duration(parts.seconds)*time.Second
+
+	if parts.negative {
+		d = -d
+	}
+	return d, nil
+}
+
+// Period converts the value to a period. Unlike Duration, this keeps the distinction
+// between nominal days and weeks and exact hours, minutes and seconds.
+func (v DurationValue) Period() (period.Period, error) {
+	if err := v.Validate(); err != nil {
+		return period.Period{}, err
+	}
+	return period.Parse(strings.TrimPrefix(v.Value, "+"))
+}
+
+// durationParts holds the parts of an RFC-5545 duration.
+type durationParts struct {
+	negative                             bool
+	weeks, days, hours, minutes, seconds int64
+}
+
+// parseDurationParts reads an ISO-8601 duration, rejecting years, months and
+// fractions because RFC-5545 durations cannot have them.
+func parseDurationParts(s string) (durationParts, error) {
+	var parts durationParts
+	iso := s
+
+	switch {
+	case strings.HasPrefix(s, "-"):
+		parts.negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return parts, fmt.Errorf("%q is not a valid duration", iso)
+	}
+
+	inTime := false
+	var n int64
+	digits := 0
+	for _, c := range s[1:] {
+		switch {
+		case c >= '0' && c <= '9':
+			n = n*10 + int64(c-'0')
+			digits++
+			continue
+		case c == 'T' && !inTime && digits == 0:
+			inTime = true
+			continue
+		case digits == 0:
+			return parts, fmt.Errorf("%q is not a valid duration", iso)
+		}
+
+		switch {
+		case c == 'W' && !inTime:
+			parts.weeks = n
+		case c == 'D' && !inTime:
+			parts.days = n
+		case c == 'H' && inTime:
+			parts.hours = n
+		case c == 'M' && inTime:
+			parts.minutes = n
+		case c == 'S' && inTime:
+			parts.seconds = n
+		case c == 'Y' || c == 'M':
+			return parts, fmt.Errorf("%q has years or months, which RFC-5545 durations cannot express", iso)
+		default:
+			return parts, fmt.Errorf("%q is not a valid RFC-5545 duration", iso)
+		}
+		n, digits = 0, 0
+	}
+
+	if digits > 0 {
+		return parts, fmt.Errorf("%q is not a valid duration", iso)
+	}
+	return parts, nil
+}
+
+// normalise expresses weeks as days unless the duration is only weeks, because
+// RFC-5545 does not allow weeks to be combined with other parts.
+func (p durationParts) normalise() durationParts {
+	if p.weeks > 0 && p.days+p.hours+p.minutes+p.seconds > 0 {
+		p.days += p.weeks * 7
+		p.weeks = 0
+	} else if p.weeks == 0 && p.days > 0 && p.days%7 == 0 && p.hours+p.minutes+p.seconds == 0 {
+		p.weeks, p.days = p.days/7, 0
+	}
+	return p
+}
+
+// String formats the duration as required by RFC-5545.
+func (p durationParts) String() string {
+	b := &strings.Builder{}
+	if p.negative {
+		b.WriteByte('-')
+	}
+	b.WriteByte('P')
+
+	if p.weeks > 0 {
+		fmt.Fprintf(b, "%dW", p.weeks)
+		return b.String()
+	}
+
+	if p.days > 0 {
+		fmt.Fprintf(b, "%dD", p.days)
+	}
+
+	if p.hours+p.minutes+p.seconds > 0 || p.days == 0 {
+		b.WriteByte('T')
+		if p.hours > 0 {
+			fmt.Fprintf(b, "%dH", p.hours)
+		}
+		// RFC-5545 does not allow hours and seconds without minutes
+		if p.minutes > 0 || (p.hours > 0 && p.seconds > 0) {
+			fmt.Fprintf(b, "%dM", p.minutes)
+		}
+		if p.seconds > 0 || p.hours+p.minutes == 0 {
+			fmt.Fprintf(b, "%dS", p.seconds)
+		}
+	}
+
+	return b.String()
+}
+
 // IsTrigger allows duration to be used for triggers.
 func (v DurationValue) IsTrigger() {}
 
@@ -247,6 +563,11 @@ func Integer(number int) IntegerValue {
 	}
 }
 
+// Params gets the parameters of the value.
+func (v IntegerValue) Params() parameter.Parameters {
+	return v.Parameters
+}
+
 // IsDefined tests whether the value has been explicitly defined or is default.
 func (v IntegerValue) IsDefined() bool {
 	return v.defined
@@ -261,7 +582,9 @@ func (v IntegerValue) With(params ...parameter.Parameter) IntegerValue {
 // WriteTo writes the value to the writer.
 // This is part of the Valuer interface.
 func (v IntegerValue) WriteTo(w ics.StringWriter) error {
-	v.Parameters.WriteTo(w)
+	if err := v.Parameters.WriteTo(w); err != nil {
+		return err
+	}
 	w.WriteByte(':')
 	_, e := w.WriteString(strconv.Itoa(v.Value))
 	return e
@@ -295,6 +618,11 @@ func Geo(lat, lon float64) GeoValue {
 	}
 }
 
+// Params gets the parameters of the value.
+func (v GeoValue) Params() parameter.Parameters {
+	return v.Parameters
+}
+
 // IsDefined tests whether the value has been explicitly defined or is default.
 func (v GeoValue) IsDefined() bool {
 	return v.defined
@@ -309,7 +637,9 @@ func (v GeoValue) With(params ...parameter.Parameter) GeoValue {
 // WriteTo writes the value to the writer.
 // This is part of the Valuer interface.
 func (v GeoValue) WriteTo(w ics.StringWriter) error {
-	v.Parameters.WriteTo(w)
+	if err := v.Parameters.WriteTo(w); err != nil {
+		return err
+	}
 	w.WriteByte(':')
 	w.WriteString(strconv.FormatFloat(v.Lat, 'G', -1, 64))
 	w.WriteByte(';')
@@ -333,6 +663,11 @@ func Binary(data []byte) BinaryValue {
 	}
 }
 
+// Params gets the parameters of the value.
+func (v BinaryValue) Params() parameter.Parameters {
+	return v.Parameters
+}
+
 // IsDefined tests whether the value has been explicitly defined or is default.
 func (v BinaryValue) IsDefined() bool {
 	return len(v.Value) > 0
@@ -351,7 +686,9 @@ func (v BinaryValue) With(params ...parameter.Parameter) BinaryValue {
 // WriteTo writes the value to the writer.
 // This is part of the Writable interface.
 func (v BinaryValue) WriteTo(w ics.StringWriter) error {
-	v.Parameters.WriteTo(w)
+	if err := v.Parameters.WriteTo(w); err != nil {
+		return err
+	}
 	w.WriteByte(':')
 	// RFC5545 requires 'standard' encoding (using alphanum, +, /) with padding.
 	encoder := base64.NewEncoder(base64.StdEncoding, w)
@@ -4,11 +4,85 @@ import (
 	"bytes"
 	"github.com/rickb777/ical2/ics"
 	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/period"
 	"strings"
 	"testing"
 	"time"
 )
 
+func TestDateTimeForms(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	etcUTC, _ := time.LoadLocation("UTC")
+
+	utcJanNoon := time.Date(2014, time.Month(1), 1, 12, 0, 0, 0, time.UTC)
+	berlinJanNoon := time.Date(2014, time.Month(1), 2, 12, 0, 0, 0, berlin)
+	berlinJulyNoon := time.Date(2014, time.Month(7), 2, 12, 0, 0, 0, berlin)
+
+	cases := []struct {
+		dt  DateTimeValue
+		exp string
+	}{
+		{Floating(utcJanNoon), ";VALUE=DATE-TIME:20140101T120000"},
+		{Floating(berlinJanNoon), ";VALUE=DATE-TIME:20140102T120000"},
+		{Floating(berlinJanNoon).With(parameter.TZid("Europe/Berlin")), ";VALUE=DATE-TIME:20140102T120000"},
+
+		{UTC(utcJanNoon), ";VALUE=DATE-TIME:20140101T120000Z"},
+		{UTC(berlinJanNoon, berlinJulyNoon), ";VALUE=DATE-TIME:20140102T110000Z,20140702T100000Z"},
+		{UTC(berlinJanNoon).With(parameter.TZid("Europe/Berlin")), ";VALUE=DATE-TIME:20140102T110000Z"},
+
+		{Zoned(berlinJanNoon), ";VALUE=DATE-TIME;TZID=Europe/Berlin:20140102T120000"},
+		{Zoned(berlinJanNoon, berlinJulyNoon), ";VALUE=DATE-TIME;TZID=Europe/Berlin:20140102T120000,20140702T120000"},
+		{Zoned(utcJanNoon), ";VALUE=DATE-TIME:20140101T120000Z"},
+		{Zoned(utcJanNoon.In(etcUTC)), ";VALUE=DATE-TIME:20140101T120000Z"},
+		{Zoned(berlinJanNoon).AsDate(), ";TZID=Europe/Berlin;VALUE=DATE:20140102"},
+	}
+
+	for i, c := range cases {
+		b := &strings.Builder{}
+		if err := c.dt.WriteTo(b); err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+		if s := b.String(); s != c.exp {
+			t.Errorf("%d: expected %s, got %s", i, c.exp, s)
+		}
+	}
+}
+
+func TestDateTimeInconsistentZones(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	paris, _ := time.LoadLocation("Europe/Paris")
+
+	berlinJanNoon := time.Date(2014, time.Month(1), 2, 12, 0, 0, 0, berlin)
+	parisJanNoon := time.Date(2014, time.Month(1), 3, 12, 0, 0, 0, paris)
+	localJanNoon := time.Date(2014, time.Month(1), 2, 12, 0, 0, 0, time.Local)
+
+	cases := []DateTimeValue{
+		Zoned(berlinJanNoon, parisJanNoon),
+		Floating(berlinJanNoon, parisJanNoon),
+		DateTime(berlinJanNoon, parisJanNoon.UTC()),
+		Date(berlinJanNoon, parisJanNoon),
+		Zoned(localJanNoon),
+	}
+
+	for i, c := range cases {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+
+		b := &strings.Builder{}
+		if err := c.WriteTo(b); err == nil || b.Len() > 0 {
+			t.Errorf("%d: got %v %q", i, err, b.String())
+		}
+	}
+
+	// parsed lists that mix UTC and local times
+	for _, s := range []string{"20140102T120000Z,20140103T120000", "20140102T120000,20140103T120000Z"} {
+		if v, err := ParseDateTime(nil, s); err == nil {
+			t.Errorf("%s: got %v", s, v)
+		}
+	}
+}
+
 func TestDateTimeZero(t *testing.T) {
 	defined := TStamp(time.Time{}).IsDefined()
 	if defined {
@@ -192,3 +266,117 @@ func TestDefaultValuesShouldNotBeDefined(t *testing.T) {
 		}
 	}
 }
+
+func TestDurationConversion(t *testing.T) {
+	cases := []struct {
+		v   string
+		exp time.Duration
+	}{
+		{"PT12H", 12 * time.Hour},
+		{"-PT15M", -15 * time.Minute},
+		{"+PT90S", 90 * time.Second},
+		{"P1W", 7 * 24 * time.Hour},
+		{"P1DT2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second},
+	}
+
+	for i, c := range cases {
+		d, err := Duration(c.v).Duration()
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+		if d != c.exp {
+			t.Errorf("%d: expected %v, got %v", i, c.exp, d)
+		}
+	}
+
+	if _, err := Duration("1 hour").Duration(); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestDurationOf(t *testing.T) {
+	cases := []struct {
+		d   time.Duration
+		exp string
+	}{
+		{0, "PT0S"},
+		{15 * time.Minute, "PT15M"},
+		{-15 * time.Minute, "-PT15M"},
+		{time.Hour + 5*time.Second, "PT1H0M5S"},
+		{90*time.Second + 300*time.Millisecond, "PT1M30S"},
+		{24 * time.Hour, "PT24H"},
+		{26 * time.Hour, "PT26H"},
+		{7 * 24 * time.Hour, "PT168H"},
+		{-14 * 24 * time.Hour, "-PT336H"},
+		{8*24*time.Hour + time.Minute, "PT192H1M"},
+	}
+
+	for i, c := range cases {
+		v := DurationOf(c.d)
+		if v.Value != c.exp {
+			t.Errorf("%d: expected %s, got %s", i, c.exp, v.Value)
+		}
+		if err := v.Validate(); err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+	}
+}
+
+func TestDurationOfPeriod(t *testing.T) {
+	cases := []struct {
+		p   string
+		exp string
+	}{
+		{"P1W", "P1W"},
+		{"P1DT2H", "P1DT2H"},
+		{"-PT15M", "-PT15M"},
+		{"P1W2D", "P9D"},
+		{"P14D", "P2W"},
+		{"PT1H30S", "PT1H0M30S"},
+	}
+
+	for i, c := range cases {
+		v, err := DurationOfPeriod(period.MustParse(c.p))
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+		if v.Value != c.exp {
+			t.Errorf("%d: expected %s, got %s", i, c.exp, v.Value)
+		}
+
+		// the period library formats the duration in its own way, so compare lengths
+		p, err := v.Period()
+		if err != nil || p.DurationApprox() != period.MustParse(c.p).DurationApprox() {
+			t.Errorf("%d: got %v %v", i, p, err)
+		}
+	}
+
+	for i, bad := range []string{"P1Y", "P2M", "PT1.5S"} {
+		if _, err := DurationOfPeriod(period.MustParse(bad)); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+}
+
+func TestInvalidDurationIsRejected(t *testing.T) {
+	for i, bad := range []string{"1 hour", "PT", "P1H", "PT1H5S", "P1W2D", "PT0.5S"} {
+		v := Duration(bad)
+		if err := v.Validate(); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+
+		b := &bytes.Buffer{}
+		x := ics.NewBuffer(b, "\n")
+		if err := x.WriteValuerLine(true, "DURATION", v); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+		x.Flush()
+		if b.Len() > 0 {
+			t.Errorf("%d: got %q", i, b.String())
+		}
+
+		if _, err := v.Period(); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package value
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/rickb777/date/v2/timespan"
+	"github.com/rickb777/ical2/parameter"
+	"github.com/rickb777/ical2/parameter/value"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The parsing functions in this file are the counterparts of the WriteTo methods.
+// In each case, the parameters are those that were read from the content line;
+// they are retained as-is so that values can be written out again unaltered.
+
+// floating is the location used for date-times that have neither a TZID parameter
+// nor the Zulu "Z" suffix. Its name is deliberately not "UTC".
+var floating = time.FixedZone("", 0)
+
+// ParseText reads a text value, reversing the escaping of backslash, semicolon,
+// comma and newline.
+func ParseText(params parameter.Parameters, s string) TextValue {
+	return TextValue{baseValue{Parameters: params, Value: unescapeText(s), format: text}}
+}
+
+// ParseList reads a comma-separated list of text values.
+func ParseList(params parameter.Parameters, s string) ListValue {
+	parts := splitEscaped(s, ',')
+	for i, p := range parts {
+		parts[i] = unescapeText(p)
+	}
+	return ListValue{baseValue{Parameters: params, Value: parts[0], Others: parts[1:], format: text}}
+}
+
+// ParseURI reads a URI value.
+func ParseURI(params parameter.Parameters, s string) URIValue {
+	return URIValue{baseValue{Parameters: params, Value: s, format: verbatim}}
+}
+
+// ParseRaw reads a value verbatim.
+func ParseRaw(params parameter.Parameters, s string) RawValue {
+	return RawValue{baseValue{Parameters: params, Value: s, format: verbatim}}
+}
+
+// ErrUnknownTimeZone is returned when a TZID parameter names a time zone that is
+// neither in the time zone database nor in the TimeZones used for parsing.
+var ErrUnknownTimeZone = errors.New("unknown time zone")
+
+// TimeZones holds the locations of time zones that are defined by VTIMEZONE
+// components rather than by the time zone database, keyed by TZID. Its methods
+// parse values that may have TZID parameters; the corresponding functions are
+// the same as using nil TimeZones, which only knows the time zone database.
+type TimeZones map[string]*time.Location
+
+// location finds the location for a TZID, preferring those defined in the calendar.
+// "Local" is not accepted because its meaning depends on where the code is run.
+func (zz TimeZones) location(tzid string) (*time.Location, error) {
+	if loc, exists := zz[tzid]; exists {
+		return loc, nil
+	}
+	if tzid != "" && tzid != "Local" {
+		if loc, err := time.LoadLocation(tzid); err == nil {
+			return loc, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownTimeZone, tzid)
+}
+
+// ParseDateTime reads a date-time value, or a date value if the VALUE=DATE parameter
+// is present. The TZID parameter, if any, determines the location; if this is not a
+// known location, an error wrapping ErrUnknownTimeZone is returned.
+// A comma-separated list of values is also accepted.
+func ParseDateTime(params parameter.Parameters, s string) (DateTimeValue, error) {
+	return TimeZones(nil).ParseDateTime(params, s)
+}
+
+// ParseDateTime reads a date-time value, or a date value if the VALUE=DATE parameter
+// is present. The TZID parameter, if any, determines the location, which is looked
+// up in zz before the time zone database; if it is not found, an error wrapping
+// ErrUnknownTimeZone is returned.
+// A comma-separated list of values is also accepted, provided that the date-times
+// are either all UTC or all local times.
+//
+// The value keeps its form when it is written: UTC date-times are written with the
+// "Z" suffix, those with a TZID keep it and the others remain floating.
+func (zz TimeZones) ParseDateTime(params parameter.Parameters, s string) (DateTimeValue, error) {
+	includeTime := true
+	if p, ok := params.Get(value.VALUE); ok && strings.EqualFold(p.Value, "DATE") {
+		includeTime = false
+	}
+
+	loc := floating
+	if p, ok := params.Get(parameter.TZID); ok {
+		var err error
+		loc, err = zz.location(p.Value)
+		if err != nil {
+			return DateTimeValue{}, err
+		}
+	}
+
+	parts := strings.Split(s, ",")
+	times := make([]time.Time, len(parts))
+	utc := false
+	for i, p := range parts {
+		t, z, err := parseTime(p, includeTime, loc)
+		if err != nil {
+			return DateTimeValue{}, err
+		}
+		if i > 0 && z != utc {
+			return DateTimeValue{}, fmt.Errorf("%q mixes UTC and local times", s)
+		}
+		times[i] = t
+		utc = z
+	}
+
+	form := floatingForm
+	switch {
+	case utc:
+		form = utcForm
+	case loc != floating:
+		form = zonedForm
+	}
+
+	return DateTimeValue{
+		Parameters:  params,
+		Value:       times[0],
+		Others:      times[1:],
+		includeTime: includeTime,
+		form:        form,
+	}, nil
+}
+
+func parseTime(s string, includeTime bool, loc *time.Location) (time.Time, bool, error) {
+	if !includeTime {
+		t, err := time.ParseInLocation(dateLayout, s, loc)
+		return t, false, err
+	}
+
+	if strings.HasSuffix(s, "Z") {
+		t, err := time.Parse(dateTimeLayoutZ, s)
+		return t, true, err
+	}
+
+	t, err := time.ParseInLocation(dateTimeLayout, s, loc)
+	return t, false, err
+}
+
+// ParsePeriod reads a period of time, which is either a start and end date-time or
+// a start date-time and a duration. The TZID parameter, if any, determines the
+// location of local date-times; if this is not a known location, an error wrapping
+// ErrUnknownTimeZone is returned.
+func ParsePeriod(params parameter.Parameters, s string) (PeriodValue, error) {
+	return TimeZones(nil).ParsePeriod(params, s)
+}
+
+// ParsePeriod reads a period of time, which is either a start and end date-time or
+// a start date-time and a duration. The TZID parameter, if any, is looked up as for
+// ParseDateTime; otherwise local date-times are read as UTC.
+func (zz TimeZones) ParsePeriod(params parameter.Parameters, s string) (PeriodValue, error) {
+	loc := time.UTC
+	if p, ok := params.Get(parameter.TZID); ok {
+		var err error
+		loc, err = zz.location(p.Value)
+		if err != nil {
+			return PeriodValue{}, err
+		}
+	}
+
+	ts, err := timespan.ParseRFC5545InLocation(s, loc)
+	if err != nil {
+		return PeriodValue{}, err
+	}
+	return PeriodValue{Parameters: params, Value: ts}, nil
+}
+
+// ParseTemporal reads a date-time or, if the VALUE=PERIOD parameter is present, a period.
+func ParseTemporal(params parameter.Parameters, s string) (Temporal, error) {
+	return TimeZones(nil).ParseTemporal(params, s)
+}
+
+// ParseTemporal reads a date-time or, if the VALUE=PERIOD parameter is present, a period.
+// Time zones are looked up in zz (see ParseDateTime).
+func (zz TimeZones) ParseTemporal(params parameter.Parameters, s string) (Temporal, error) {
+	if p, ok := params.Get(value.VALUE); ok && strings.EqualFold(p.Value, "PERIOD") {
+		return zz.ParsePeriod(params, s)
+	}
+	return zz.ParseDateTime(params, s)
+}
+
+var durationPattern = regexp.MustCompile(`^[+-]?P(\d+W|\d+D(T(\d+H(\d+M(\d+S)?)?|\d+M(\d+S)?|\d+S))?|T(\d+H(\d+M(\d+S)?)?|\d+M(\d+S)?|\d+S))$`)
+
+// ParseDuration reads a duration, checking that it is in the form required by
+// https://tools.ietf.org/html/rfc5545#section-3.3.6.
+func ParseDuration(params parameter.Parameters, s string) (DurationValue, error) {
+	if !durationPattern.MatchString(s) {
+		return DurationValue{}, fmt.Errorf("%q is not a valid duration", s)
+	}
+	return DurationValue{baseValue{Parameters: params, Value: s, format: verbatim}}, nil
+}
+
+// ParseTrigger reads an alarm trigger, which is a duration or, if the VALUE=DATE-TIME
+// parameter is present, a date-time.
+func ParseTrigger(params parameter.Parameters, s string) (Trigger, error) {
+	return TimeZones(nil).ParseTrigger(params, s)
+}
+
+// ParseTrigger reads an alarm trigger, which is a duration or, if the VALUE=DATE-TIME
+// parameter is present, a date-time. Date-times are read using zz (see ParseDateTime).
+func (zz TimeZones) ParseTrigger(params parameter.Parameters, s string) (Trigger, error) {
+	if p, ok := params.Get(value.VALUE); ok && strings.EqualFold(p.Value, value.DATE_TIME) {
+		return zz.ParseDateTime(params, s)
+	}
+	return ParseDuration(params, s)
+}
+
+// ParseInteger reads an integer value.
+func ParseInteger(params parameter.Parameters, s string) (IntegerValue, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return IntegerValue{}, err
+	}
+	return IntegerValue{Parameters: params, Value: n, defined: true}, nil
+}
+
+// ParseGeo reads a latitude and longitude pair.
+func ParseGeo(params parameter.Parameters, s string) (GeoValue, error) {
+	lat, lon, ok := strings.Cut(s, ";")
+	if !ok {
+		return GeoValue{}, fmt.Errorf("%q is not a valid geo value", s)
+	}
+
+	v := GeoValue{Parameters: params, defined: true}
+	var err error
+	v.Lat, err = strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return GeoValue{}, err
+	}
+	v.Lon, err = strconv.ParseFloat(lon, 64)
+	if err != nil {
+		return GeoValue{}, err
+	}
+	return v, nil
+}
+
+// ParseBinary reads base64-encoded binary data.
+func ParseBinary(params parameter.Parameters, s string) (BinaryValue, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return BinaryValue{}, err
+	}
+	return BinaryValue{Parameters: params, Value: data}, nil
+}
+
+// ParseAttachable reads an attachment, which is a URI or, if the VALUE=BINARY parameter
+// is present, inline binary data.
+func ParseAttachable(params parameter.Parameters, s string) (Attachable, error) {
+	if p, ok := params.Get(value.VALUE); ok && strings.EqualFold(p.Value, "BINARY") {
+		return ParseBinary(params, s)
+	}
+	return ParseURI(params, s), nil
+}
+
+// ParseRecurrence reads a recurrence rule.
+func ParseRecurrence(params parameter.Parameters, s string) (RecurrenceValue, error) {
+	v := RecurrenceValue{Parameters: params}
+
+	for _, part := range strings.Split(s, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return RecurrenceValue{}, fmt.Errorf("%q is not a valid recurrence rule part", part)
+		}
+
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			v.Freq = strings.ToUpper(val)
+		case "INTERVAL":
+			v.Interval, err = parseUint(val)
+		case "COUNT":
+			v.Count, err = parseUint(val)
+		case "UNTIL":
+			v.Until, err = parseUntil(val)
+		case "BYWEEKNO":
+			v.ByWeekNo, err = parseIntList(val)
+		case "BYMONTH":
+			v.ByMonth, err = parseUintList(val)
+		case "BYHOUR":
+			v.ByHour, err = parseUintList(val)
+		case "BYMINUTE":
+			v.ByMinute, err = parseUintList(val)
+		case "BYSECOND":
+			v.BySecond, err = parseUintList(val)
+		case "BYDAY":
+			v.ByDay, err = parseWeekDayNumList(val)
+		case "BYMONTHDAY":
+			v.ByMonthDay, err = parseIntList(val)
+		case "BYYEARDAY":
+			v.ByYearDay, err = parseIntList(val)
+		case "BYSETPOS":
+			v.BySetPos, err = parseIntList(val)
+		case "WKST":
+			v.WeekStart, err = parseWeekday(val)
+		default:
+			err = fmt.Errorf("unsupported recurrence rule part")
+		}
+
+		if err != nil {
+			return RecurrenceValue{}, fmt.Errorf("%s: %w", part, err)
+		}
+	}
+
+	if v.Freq == "" {
+		return RecurrenceValue{}, fmt.Errorf("%q: FREQ is required", s)
+	}
+
+	return v, v.Validate()
+}
+
+func parseUntil(s string) (time.Time, error) {
+	if len(s) == len(dateLayout) {
+		return time.Parse(dateLayout, s)
+	}
+	return time.Parse(dateTimeLayoutZ, s)
+}
+
+func parseUint(s string) (uint, error) {
+	n, err := strconv.ParseUint(s, 10, 32)
+	return uint(n), err
+}
+
+func parseIntList(s string) ([]int, error) {
+	parts := strings.Split(s, ",")
+	list := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = n
+	}
+	return list, nil
+}
+
+func parseUintList(s string) ([]uint, error) {
+	parts := strings.Split(s, ",")
+	list := make([]uint, len(parts))
+	for i, p := range parts {
+		n, err := parseUint(p)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = n
+	}
+	return list, nil
+}
+
+func parseWeekday(s string) (Weekday, error) {
+	s = strings.ToUpper(s)
+	for i := Sunday; i <= Saturday; i++ {
+		if days[i] == s {
+			return i, nil
+		}
+	}
+	return Undefined, fmt.Errorf("%q is not a weekday", s)
+}
+
+func parseWeekDayNumList(s string) ([]WeekDayNum, error) {
+	parts := strings.Split(s, ",")
+	list := make([]WeekDayNum, len(parts))
+	for i, p := range parts {
+		if len(p) < 2 {
+			return nil, fmt.Errorf("%q is not a weekday", p)
+		}
+
+		wd, err := parseWeekday(p[len(p)-2:])
+		if err != nil {
+			return nil, err
+		}
+
+		ord := 0
+		if len(p) > 2 {
+			ord, err = strconv.Atoi(p[:len(p)-2])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		list[i] = WeekDayNum{OrdWk: ord, WeekDay: wd}
+	}
+	return list, nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// unescapeText reverses escapeText.
+func unescapeText(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			i++
+			c = s[i]
+			if c == 'n' || c == 'N' {
+				c = '\n'
+			}
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// splitEscaped splits s at each separator that is not preceded by a backslash escape.
+// The escapes are retained in the parts.
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
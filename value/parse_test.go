@@ -0,0 +1,124 @@
+package value
+
+import (
+	"bytes"
+	"errors"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
+	"testing"
+	"time"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	cases := []struct {
+		params string
+		input  string
+		parse  func(parameter.Parameters, string) (ics.Valuer, error)
+	}{
+		{"", `a\, b\; c\\d\ne`, func(pp parameter.Parameters, s string) (ics.Valuer, error) { return ParseText(pp, s), nil }},
+		{"", `APPOINTMENT,EDUCATION\,TRAINING`, func(pp parameter.Parameters, s string) (ics.Valuer, error) { return ParseList(pp, s), nil }},
+		{";VALUE=URI", "http://example.com/a?b=c,d", func(pp parameter.Parameters, s string) (ics.Valuer, error) { return ParseURI(pp, s), nil }},
+		{";X-FOO=1", "geo:37.3,-122.0", func(pp parameter.Parameters, s string) (ics.Valuer, error) { return ParseRaw(pp, s), nil }},
+		{"", "20140101T120000Z", wrap(ParseDateTime)},
+		{";VALUE=DATE-TIME;TZID=Europe/Berlin", "20140102T120000", wrap(ParseDateTime)},
+		{";VALUE=DATE-TIME;TZID=UTC", "20140102T120000,20140103T120000", wrap(ParseDateTime)},
+		{"", "20140101T120000Z,20140102T120000Z", wrap(ParseDateTime)},
+		{";VALUE=DATE-TIME;TZID=My Zone", "20140102T120000", wrap(TimeZones{"My Zone": time.FixedZone("My Zone", 3600)}.ParseDateTime)},
+		{";VALUE=DATE", "20140102,20140103", wrap(ParseDateTime)},
+		{"", "20140102T120000", wrap(ParseDateTime)},
+		{";VALUE=PERIOD", "20140203T120405Z/PT1H", wrap(ParsePeriod)},
+		{";VALUE=DURATION", "-PT10M", wrap(ParseDuration)},
+		{"", "P1W", wrap(ParseDuration)},
+		{"", "P2DT3H", wrap(ParseDuration)},
+		{";VALUE=INTEGER", "-3", wrap(ParseInteger)},
+		{";VALUE=FLOAT", "37.386013;-122.082932", wrap(ParseGeo)},
+		{";VALUE=BINARY;ENCODING=BASE64", "QX1+Qg==", wrap(ParseBinary)},
+		{"", "FREQ=MONTHLY;COUNT=10;BYDAY=1FR,-1SU;WKST=MO", wrap(ParseRecurrence)},
+		{"", "FREQ=DAILY;UNTIL=19971224T000000Z", wrap(ParseRecurrence)},
+	}
+
+	for i, c := range cases {
+		pp, err := parameter.Parse(c.params)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %v", i, err)
+		}
+
+		v, err := c.parse(pp, c.input)
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+
+		b := &bytes.Buffer{}
+		v.WriteTo(b)
+		exp := c.params + ":" + c.input
+		if s := b.String(); s != exp {
+			t.Errorf("%d: expected %q but got %q", i, exp, s)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		parse func(parameter.Parameters, string) (ics.Valuer, error)
+	}{
+		{"2014-01-01", wrap(ParseDateTime)},
+		{"1 hour", wrap(ParseDuration)},
+		{"PT", wrap(ParseDuration)},
+		{"P1H", wrap(ParseDuration)},
+		{"x", wrap(ParseInteger)},
+		{"37.3", wrap(ParseGeo)},
+		{"!!", wrap(ParseBinary)},
+		{"COUNT=3", wrap(ParseRecurrence)},
+		{"FREQ=DAILY;BYMONTH=13", wrap(ParseRecurrence)},
+		{"FREQ=DAILY;BYDAY=XX", wrap(ParseRecurrence)},
+	}
+
+	for i, c := range cases {
+		_, err := c.parse(nil, c.input)
+		if err == nil {
+			t.Errorf("%d: expected an error for %q", i, c.input)
+		}
+	}
+}
+
+func wrap[V ics.Valuer](fn func(parameter.Parameters, string) (V, error)) func(parameter.Parameters, string) (ics.Valuer, error) {
+	return func(pp parameter.Parameters, s string) (ics.Valuer, error) {
+		return fn(pp, s)
+	}
+}
+
+func TestParseUnknownTimeZone(t *testing.T) {
+	for i, tzid := range []string{"My Zone", "Local", ""} {
+		pp := parameter.Parameters{parameter.TZid(tzid)}
+		if _, err := ParseDateTime(pp, "20140102T120000"); !errors.Is(err, ErrUnknownTimeZone) {
+			t.Errorf("%d: got %v", i, err)
+		}
+		if _, err := ParsePeriod(pp, "20140102T120000/PT1H"); !errors.Is(err, ErrUnknownTimeZone) {
+			t.Errorf("%d: got %v", i, err)
+		}
+	}
+}
+
+func TestParseZonedPeriod(t *testing.T) {
+	pp := parameter.Parameters{parameter.TZid("My Zone")}
+	zz := TimeZones{"My Zone": time.FixedZone("My Zone", 3600)}
+
+	for i, parse := range []func(parameter.Parameters, string) (ics.Valuer, error){wrap(zz.ParsePeriod), wrap(zz.ParseTemporal)} {
+		v, err := parse(append(pp, parameter.Parameter{Key: "VALUE", Value: "PERIOD"}), "20140102T120000/PT1H")
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+
+		if start := v.(PeriodValue).Value.Start(); !start.Equal(time.Date(2014, 1, 2, 11, 0, 0, 0, time.UTC)) {
+			t.Errorf("%d: got %v", i, start)
+		}
+
+		b := &bytes.Buffer{}
+		v.WriteTo(b)
+		if s := b.String(); s != ";VALUE=PERIOD:20140102T110000Z/PT1H" {
+			t.Errorf("%d: got %q", i, s)
+		}
+	}
+}
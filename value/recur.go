@@ -102,6 +102,11 @@ func Recurrence(freq string) RecurrenceValue {
 	}
 }
 
+// Params gets the parameters of the value.
+func (v RecurrenceValue) Params() parameter.Parameters {
+	return v.Parameters
+}
+
 // IsDefined tests whether the value has been explicitly defined or is default.
 func (v RecurrenceValue) IsDefined() bool {
 	return v.Freq != ""
@@ -121,7 +126,9 @@ func (v RecurrenceValue) WriteTo(w ics.StringWriter) (err error) {
 		return err
 	}
 
-	v.Parameters.WriteTo(w)
+	if err := v.Parameters.WriteTo(w); err != nil {
+		return err
+	}
 	w.WriteByte(':')
 	_, err = w.WriteString("FREQ")
 	w.WriteByte('=')
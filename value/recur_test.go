@@ -9,7 +9,6 @@ import (
 )
 
 func TestRecur(t *testing.T) {
-	ics.MaxLineLength = 120
 	dec24 := time.Date(1997, time.Month(12), 24, 0, 0, 0, 0, time.UTC)
 	jan31 := time.Date(2000, time.Month(1), 31, 14, 0, 0, 0, time.UTC)
 
@@ -359,10 +358,8 @@ func TestRecur(t *testing.T) {
 func doTestRecur(t *testing.T, v func() RecurrenceValue, exp string) {
 	t.Helper()
 
-	ics.MaxLineLength = 1000 // disable line folding
-
 	b := &bytes.Buffer{}
-	x := ics.NewFoldWriter(b, "\n")
+	x := ics.Options{LineEnding: "\n", NoFolding: true}.NewFoldWriter(b)
 
 	v().WriteTo(x)
 
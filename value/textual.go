@@ -1,18 +1,24 @@
 package value
 
 import (
-	"bytes"
+	"fmt"
 	"github.com/rickb777/ical2/ics"
 	"github.com/rickb777/ical2/parameter"
 	"github.com/rickb777/ical2/parameter/value"
 	"strings"
+	"unicode/utf8"
 )
 
 type baseValue struct {
 	Parameters parameter.Parameters
 	Value      string
 	Others     []string
-	escape     func(string) string
+	format     *format
+}
+
+// Params gets the parameters of the value.
+func (v baseValue) Params() parameter.Parameters {
+	return v.Parameters
 }
 
 // IsDefined tests whether the value has been explicitly defined or is default.
@@ -22,13 +28,27 @@ func (v baseValue) IsDefined() bool {
 
 // WriteTo writes the value to the writer.
 // This is part of the Valuer interface.
+// Values containing unsafe control characters are rejected and nothing is written.
 func (v baseValue) WriteTo(w ics.StringWriter) (err error) {
-	v.Parameters.WriteTo(w)
-	w.WriteString(":")
-	_, err = w.WriteString(v.escape(v.Value))
+	controls := ics.OptionsOf(w).TextControls
+	if err = v.format.check(v.Value, controls); err != nil {
+		return err
+	}
+	for _, o := range v.Others {
+		if err = v.format.check(o, controls); err != nil {
+			return err
+		}
+	}
+
+	if err = v.Parameters.WriteTo(w); err != nil {
+		return err
+	}
+
+	w.WriteByte(':')
+	err = v.format.write(w, v.Value)
 	for _, o := range v.Others {
 		w.WriteByte(',')
-		_, err = w.WriteString(v.escape(o))
+		err = v.format.write(w, o)
 	}
 	return err
 }
@@ -47,7 +67,7 @@ func URI(uri string) URIValue {
 	return URIValue{baseValue{
 		Parameters: parameter.Parameters{value.URI()},
 		Value:      uri,
-		escape:     noOp,
+		format:     verbatim,
 	}}
 }
 
@@ -56,7 +76,7 @@ func CalAddress(mailto string) URIValue {
 	if !strings.HasPrefix(mailto, "mailto:") {
 		mailto = "mailto:" + mailto
 	}
-	return URIValue{baseValue{Value: mailto, escape: noOp}}
+	return URIValue{baseValue{Value: mailto, format: verbatim}}
 }
 
 // With appends parameters to the value.
@@ -88,7 +108,7 @@ type TextValue struct {
 
 // Text constructs a new text value.
 func Text(v string) TextValue {
-	return TextValue{baseValue{Value: v, escape: escapeText}}
+	return TextValue{baseValue{Value: v, format: text}}
 }
 
 // With appends parameters to the value.
@@ -109,6 +129,26 @@ func Texts(vv ...string) []TextValue {
 
 //-------------------------------------------------------------------------------------------------
 
+// RawValue holds a value that is written verbatim, without any escaping. It is used
+// for extension properties whose value type is not known, so that their values can be
+// passed through unaltered.
+type RawValue struct {
+	baseValue
+}
+
+// Raw constructs a new raw value.
+func Raw(v string) RawValue {
+	return RawValue{baseValue{Value: v, format: verbatim}}
+}
+
+// With appends parameters to the value.
+func (v RawValue) With(params ...parameter.Parameter) RawValue {
+	v.Parameters = v.Parameters.Append(params...)
+	return v
+}
+
+//-------------------------------------------------------------------------------------------------
+
 // ListValue holds a list of one or more text values.
 type ListValue struct {
 	baseValue
@@ -127,7 +167,7 @@ type ListValue struct {
 // "OVERHEAD PROJECTOR", "SPEAKER PHONE", "TABLE", "TV", "VCR",
 // "VIDEO PHONE", "VEHICLE".
 func List(v ...string) ListValue {
-	return ListValue{baseValue{Value: v[0], Others: v[1:], escape: escapeText}}
+	return ListValue{baseValue{Value: v[0], Others: v[1:], format: text}}
 }
 
 // Lists constructs one or more list values, grouping the strings provided so that they
@@ -237,34 +277,79 @@ func Opaque() TextValue {
 
 //-------------------------------------------------------------------------------------------------
 
-func noOp(s string) string {
-	return s
+// format checks and writes the strings held by a value. Every string is checked
+// before any is written, so that nothing is written if the value is unsafe. The
+// policy comes from the writer's options.
+type format struct {
+	check func(s string, controls ics.ControlPolicy) error
+	write func(w ics.StringWriter, s string) error
+}
+
+var (
+	// verbatim is used for values that are not escaped, e.g. URIs. Control characters
+	// are rejected, except HTAB.
+	verbatim = &format{check: checkVerbatim, write: writeVerbatim}
+
+	// text is used for TEXT values, which are escaped.
+	text = &format{check: checkText, write: escapeText}
+)
+
+func checkVerbatim(s string, _ ics.ControlPolicy) error {
+	return ics.CheckControls(s, "\t")
 }
 
-// escapeText implements the escaping of semicolon, comma, backslash and
-// newline. See https://tools.ietf.org/html/rfc5545#section-3.3.11
-func escapeText(s string) string {
-	if len(s) == 0 {
-		return ""
+func writeVerbatim(w ics.StringWriter, s string) error {
+	_, err := w.WriteString(s)
+	return err
+}
+
+// checkText checks text. Invalid UTF-8 is rejected, as are control characters
+// other than HTAB and line breaks, unless the policy is ics.StripControls.
+func checkText(s string, controls ics.ControlPolicy) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("%w in %q", ics.ErrInvalidUTF8, s)
+	}
+	if err := ics.CheckControls(s, textControls); err != nil && controls != ics.StripControls {
+		return err
 	}
+	return nil
+}
 
-	w := &bytes.Buffer{}
+// textControls are the control characters allowed in text values.
+const textControls = "\t\r\n"
 
-	// treat s as a sequence of bytes, not runes
+// escapeText writes text, implementing the escaping of semicolon, comma, backslash
+// and newline. Line breaks (LF, CRLF or CR) are escaped as "\n"; HTAB is kept and
+// other control characters, which checkText allows only for ics.StripControls, are
+// removed. Runs of characters that need no escaping are written unchanged.
+// See https://tools.ietf.org/html/rfc5545#section-3.3.11
+func escapeText(w ics.StringWriter, s string) error {
+	start := 0
 	for i := 0; i < len(s); i++ {
 		c := s[i]
+		if c >= ' ' && c != 0x7f && c != '\\' && c != ';' && c != ',' || c == '\t' {
+			continue
+		}
+
+		w.WriteString(s[start:i])
+		start = i + 1
 
 		switch c {
 		case '\\', ';', ',':
 			w.WriteByte('\\')
+			w.WriteByte(c)
+
+		case '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				continue // the newline follows
+			}
+			w.WriteString(`\n`)
 
 		case '\n':
-			w.WriteByte('\\')
-			c = 'n'
+			w.WriteString(`\n`)
 		}
-
-		w.WriteByte(c)
 	}
 
-	return w.String()
+	_, err := w.WriteString(s[start:])
+	return err
 }
@@ -2,9 +2,13 @@ package value
 
 import (
 	"bytes"
+	"errors"
 	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/parameter"
 	"github.com/rickb777/ical2/parameter/value"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestTextConstructors(t *testing.T) {
@@ -99,6 +103,8 @@ func TestEscapeText(t *testing.T) {
 		{`,`, `\,`},
 		// newline
 		{"\n", `\n`},
+		// CRLF and lone CR
+		{"a\r\nb\rc", `a\nb\nc`},
 
 		// unescaped characters are unchanged
 		{"0123456789 ABCDEFGHIJKLMNOPQRSTUVWXYZ abcdefghijklmnopqrstuvwxyz",
@@ -109,9 +115,135 @@ func TestEscapeText(t *testing.T) {
 	}
 
 	for i, c := range cases {
-		got := escapeText(c.input)
-		if got != c.exp {
+		b := &strings.Builder{}
+		escapeText(b, c.input)
+		if got := b.String(); got != c.exp {
 			t.Errorf("%d: expected %s, got %s", i, c.exp, got)
 		}
 	}
 }
+
+func TestUnsafeValues(t *testing.T) {
+	cases := []ics.Valuer{
+		URI("https://example.com/\r\nORGANIZER:mailto:evil@example.com"),
+		CalAddress("a@example.com\nORGANIZER:mailto:evil@example.com"),
+		Raw("x\ry"),
+		Text("bell\a"),
+		List("a", "b\x00"),
+		Duration("PT1H\n"),
+		Text("x").With(parameter.CommonName("Joe\x00")),
+		CalAddress("a@example.com").With(parameter.Single("X BAD", "v")),
+	}
+
+	for i, c := range cases {
+		b := &bytes.Buffer{}
+		x := ics.NewBuffer(b, "\n")
+		x.WriteValuerLine(true, "X", c)
+		x.WriteValuerLine(true, "Y", Text("after"))
+		err := x.Flush()
+		if !errors.Is(err, ics.ErrUnsafe) {
+			t.Errorf("%d: expected an error but got %v", i, err)
+		}
+		if strings.Contains(b.String(), "ORGANIZER") || strings.Contains(b.String(), "after") {
+			t.Errorf("%d: got %q", i, b.String())
+		}
+	}
+}
+
+func TestFoldingKeepsUTF8Intact(t *testing.T) {
+	cases := []string{
+		strings.Repeat("µ", 100),
+		strings.Repeat("日本語のテキスト", 20),
+		"x" + strings.Repeat("😀", 40),
+	}
+
+	for i, c := range cases {
+		b := &bytes.Buffer{}
+		x := ics.NewBuffer(b, "\n")
+		x.WriteValuerLine(true, "DESCRIPTION", Text(c))
+		if err := x.Flush(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+		for j, line := range lines {
+			if len(line) > 75 || !utf8.ValidString(line) {
+				t.Errorf("%d: line %d has %d octets: %q", i, j, len(line), line)
+			}
+		}
+
+		unfolded := strings.ReplaceAll(b.String(), "\n ", "")
+		if unfolded != "DESCRIPTION:"+c+"\n" {
+			t.Errorf("%d: got %q", i, unfolded)
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	for i, v := range []ics.Valuer{Text("caf\xe9"), List("ok", "\xff")} {
+		b := &bytes.Buffer{}
+		x := ics.NewBuffer(b, "\n")
+		x.WriteValuerLine(true, "SUMMARY", v)
+		if err := x.Flush(); !errors.Is(err, ics.ErrInvalidUTF8) {
+			t.Errorf("%d: got %v", i, err)
+		}
+	}
+}
+
+func TestTextControls(t *testing.T) {
+	cases := []struct {
+		v   ics.Valuer
+		exp string
+	}{
+		{Text("a\x00b\x1bc\x7fd"), ":abcd\n"},
+		{Text("tab\there\r\nnext\rline"), ":tab\there\\nnext\\nline\n"},
+		{List("a\x07", "b\x08"), ":a,b\n"},
+	}
+
+	for i, c := range cases {
+		b := &bytes.Buffer{}
+		x := ics.NewBuffer(b, "\n")
+		x.WriteValuerLine(true, "X", c.v)
+		err := x.Flush()
+		if i != 1 && !errors.Is(err, ics.ErrUnsafe) {
+			t.Errorf("%d: got %v", i, err)
+		}
+
+		b.Reset()
+		x = ics.Options{LineEnding: "\n", TextControls: ics.StripControls}.NewBuffer(b)
+		x.WriteValuerLine(true, "X", c.v)
+		if err := x.Flush(); err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+		if s := b.String()[1:]; s != c.exp {
+			t.Errorf("%d: expected %q but got %q", i, c.exp, s)
+		}
+	}
+}
+
+// failingValue writes part of a value and then fails.
+type failingValue struct{}
+
+func (failingValue) IsDefined() bool { return true }
+
+func (failingValue) WriteTo(w ics.StringWriter) error {
+	w.WriteString(";VALUE=TEXT:partial")
+	return errors.New("failed")
+}
+
+func TestPropertiesAreWrittenAtomically(t *testing.T) {
+	b := &bytes.Buffer{}
+	x := ics.NewBuffer(b, "\n")
+	x.WriteValuerLine(true, "SUMMARY", Text("before"))
+	if err := x.WriteValuerLine(true, "RRULE", failingValue{}); err == nil || err.Error() != "RRULE: failed" {
+		t.Errorf("got %v", err)
+	}
+	x.WriteValuerLine(true, "DESCRIPTION", Text("after"))
+
+	if err := x.Flush(); err == nil {
+		t.Errorf("expected an error")
+	}
+	if s := b.String(); s != "SUMMARY:before\n" {
+		t.Errorf("got %q", s)
+	}
+}
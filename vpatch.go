@@ -0,0 +1,677 @@
+package ical2
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/value"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrDrift is returned (wrapped) when a patch cannot be applied because its
+// target differs from the calendar the patch was generated from.
+var ErrDrift = errors.New("patch target has drifted")
+
+// VPatch describes changes to a calendar, as per
+// https://tools.ietf.org/html/draft-daboo-icalendar-vpatch. It is a VComponent
+// so it can be sent in a VCALENDAR of its own.
+//
+// Paths identify the parts of the calendar being changed, for example
+//
+//	/VCALENDAR/VEVENT[UID=123][RID=20240102T100000Z]/VALARM#ATTENDEE[=mailto:x@y]
+//
+// Component names are separated by "/" and may be qualified by UID and
+// RECURRENCE-ID. A property follows "#" and may be qualified by its value.
+// Within the qualifiers, the characters "%", "[", "]", "/" and "#" are
+// percent-encoded, so a UID of "a#1" is written [UID=a%231].
+type VPatch struct {
+	// UID identifies the patch.
+	UID value.TextValue
+
+	// DTStamp is when the patch was created.
+	DTStamp value.DateTimeValue
+
+	// Patches are applied in order.
+	Patches []Patch
+}
+
+// Patch is one PATCH sub-component of a VPatch.
+type Patch struct {
+	// Target is the path to the component or property being changed. When it is
+	// a property, the patch contains exactly one property that replaces it.
+	Target string
+
+	// Delete lists the paths to components and properties that are removed.
+	Delete []string
+
+	properties []rawProperty   // added to the target
+	components []*rawComponent // added to the target
+}
+
+// NewVPatch generates a patch that will change the old calendar into the new
+// one. The DTStamp is set to the current time.
+//
+// Properties that are deleted or replaced are identified by their old values,
+// so that applying the patch to a calendar that has since been changed fails
+// instead of losing those changes.
+func NewVPatch(uid string, old, new *VCalendar) (*VPatch, error) {
+	oldRaw, err := rawCalendar(old)
+	if err != nil {
+		return nil, err
+	}
+
+	newRaw, err := rawCalendar(new)
+	if err != nil {
+		return nil, err
+	}
+
+	vp := &VPatch{UID: value.Text(uid), DTStamp: value.TStamp(time.Now())}
+
+	top := Patch{Target: "/VCALENDAR"}
+	vp.Patches = append(vp.Patches, patchProperties(&top, oldRaw, newRaw)...)
+
+	newIndex := make(map[string]*rawComponent)
+	for _, n := range newRaw.Components {
+		newIndex[componentPath(top.Target, n)] = n
+	}
+
+	matched := make(map[string]bool)
+	for _, o := range oldRaw.Components {
+		path := componentPath(top.Target, o)
+		n, exists := newIndex[path]
+		if !exists {
+			top.Delete = append(top.Delete, path)
+			continue
+		}
+
+		matched[path] = true
+		p := Patch{Target: path}
+		inPlace := patchProperties(&p, o, n)
+		if o.subComponentsString() != n.subComponentsString() {
+			// nested components have no identity, so they are replaced wholesale
+			for _, name := range subComponentNames(o.Components) {
+				p.Delete = append(p.Delete, path+"/"+name)
+			}
+			p.components = n.Components
+		}
+
+		if len(p.Delete) > 0 || len(p.properties) > 0 || len(p.components) > 0 {
+			vp.Patches = append(vp.Patches, p)
+		}
+		vp.Patches = append(vp.Patches, inPlace...)
+	}
+
+	for _, n := range newRaw.Components {
+		if !matched[componentPath(top.Target, n)] {
+			top.components = append(top.components, n)
+		}
+	}
+
+	if len(top.Delete) > 0 || len(top.properties) > 0 || len(top.components) > 0 {
+		vp.Patches = append([]Patch{top}, vp.Patches...)
+	}
+
+	return vp, nil
+}
+
+// patchProperties adds the property changes from old to new to a patch. Properties
+// whose parameters alone have changed are replaced in place by the patches returned.
+func patchProperties(p *Patch, old, new *rawComponent) []Patch {
+	var inPlace []Patch
+
+	oldProps := groupProperties(old.Properties)
+	newProps := groupProperties(new.Properties)
+
+	for _, name := range propertyNames(old.Properties, new.Properties) {
+		op, np := oldProps[name], newProps[name]
+		if sameValues(op, np) {
+			for i, o := range op {
+				if len(diffParameters(name, "", o.Parameters, np[i].Parameters)) > 0 {
+					inPlace = append(inPlace, Patch{Target: propertyPath(p.Target, o), properties: np[i : i+1]})
+				}
+			}
+			continue
+		}
+
+		for _, o := range op {
+			p.Delete = append(p.Delete, propertyPath(p.Target, o))
+		}
+		p.properties = append(p.properties, np...)
+	}
+
+	return inPlace
+}
+
+func sameValues(op, np []rawProperty) bool {
+	if len(op) != len(np) {
+		return false
+	}
+	for i, o := range op {
+		if o.Value != np[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Apply applies the patch to a calendar, returning the patched calendar. The
+// original calendar is not altered. An error wrapping ErrDrift is returned if
+// something that the patch changes is missing, or if something it adds is
+// already present.
+//
+// The patched components are decoded as usual, except that any component that
+// would lose something in doing so, such as a nested component that is not
+// modelled, is returned as a generic Component instead.
+func (vp *VPatch) Apply(c *VCalendar) (*VCalendar, error) {
+	raw, err := rawCalendar(c)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range vp.Patches {
+		if err := p.apply(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	patched, err := decodeCalendar(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, sub := range raw.Components {
+		decoded, err := rawOf(patched.VComponent[i], patched.Method)
+		if err != nil || len(compareRaw("", sub.clone(), decoded, false)) > 0 {
+			patched.VComponent[i] = componentOf(sub)
+		}
+	}
+
+	return patched, nil
+}
+
+func (p Patch) apply(root *rawComponent) error {
+	target, err := parsePath(p.Target)
+	if err != nil {
+		return err
+	}
+
+	parents, err := target.find(root)
+	if err != nil {
+		return err
+	}
+	if len(parents) != 1 {
+		return fmt.Errorf("%w: %s matches %d components", ErrDrift, p.Target, len(parents))
+	}
+	parent := parents[0]
+
+	if target.property != "" {
+		if len(p.properties) != 1 || len(p.components) != 0 || len(p.Delete) != 0 {
+			return fmt.Errorf("%s: a property target needs exactly one replacement property", p.Target)
+		}
+
+		i := target.findProperty(parent)
+		if i < 0 {
+			return fmt.Errorf("%w: %s not found", ErrDrift, p.Target)
+		}
+		parent.Properties[i] = p.properties[0]
+		return nil
+	}
+
+	for _, d := range p.Delete {
+		if err := deletePath(root, d); err != nil {
+			return err
+		}
+	}
+
+	for _, prop := range p.properties {
+		if !repeatableProperty(prop.Name) {
+			for _, existing := range parent.Properties {
+				if existing.Name == prop.Name {
+					return fmt.Errorf("%w: %s already has %s", ErrDrift, p.Target, prop.Name)
+				}
+			}
+		}
+		parent.Properties = append(parent.Properties, prop)
+	}
+
+	for _, sub := range p.components {
+		path := componentPath(p.Target, sub)
+		if strings.HasSuffix(path, "]") {
+			for _, existing := range parent.Components {
+				if componentPath(p.Target, existing) == path {
+					return fmt.Errorf("%w: %s already exists", ErrDrift, path)
+				}
+			}
+		}
+		parent.Components = append(parent.Components, sub.clone())
+	}
+
+	return nil
+}
+
+func deletePath(root *rawComponent, path string) error {
+	pp, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	if pp.property != "" {
+		found, err := pp.find(root)
+		if err != nil {
+			return err
+		}
+
+		// each path deletes one property, so duplicates need repeated paths
+		deleted := 0
+		for _, c := range found {
+			if i := pp.findProperty(c); i >= 0 {
+				c.Properties = append(c.Properties[:i], c.Properties[i+1:]...)
+				deleted++
+			}
+		}
+
+		if deleted == 0 {
+			return fmt.Errorf("%w: %s not found", ErrDrift, path)
+		}
+		return nil
+	}
+
+	if len(pp.segments) < 2 {
+		return fmt.Errorf("%s: cannot delete the calendar", path)
+	}
+
+	parentPath := pp
+	parentPath.segments = pp.segments[:len(pp.segments)-1]
+	parents, err := parentPath.find(root)
+	if err != nil {
+		return err
+	}
+
+	last := pp.segments[len(pp.segments)-1]
+	deleted := 0
+	for _, parent := range parents {
+		var kept []*rawComponent
+		for _, sub := range parent.Components {
+			if last.matches(sub) {
+				deleted++
+			} else {
+				kept = append(kept, sub)
+			}
+		}
+		parent.Components = kept
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s not found", ErrDrift, path)
+	}
+	return nil
+}
+
+// repeatableProperty is true for properties that can occur more than once.
+func repeatableProperty(name string) bool {
+	return multiValuedProperties[name] || strings.HasPrefix(name, "X-")
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type pathSegment struct {
+	name, uid, rid string
+}
+
+func (s pathSegment) matches(c *rawComponent) bool {
+	if c.Name != s.name {
+		return false
+	}
+
+	uid, rid := c.identity()
+	return (s.uid == "" || s.uid == uid) && (s.rid == "" || s.rid == rid) &&
+		(s.uid == "" || s.rid != "" || rid == "")
+}
+
+type path struct {
+	segments []pathSegment
+	property string
+	value    string
+	hasValue bool
+}
+
+// parsePath parses paths such as /VCALENDAR/VEVENT[UID=a][RID=b]#ATTENDEE[=mailto:x].
+func parsePath(s string) (path, error) {
+	var p path
+
+	comps, prop, hasProp := strings.Cut(s, "#")
+	if hasProp {
+		name, val, hasValue := strings.Cut(prop, "[=")
+		if hasValue {
+			if !strings.HasSuffix(val, "]") {
+				return p, fmt.Errorf("%s: missing ]", s)
+			}
+			v, err := url.PathUnescape(val[:len(val)-1])
+			if err != nil {
+				return p, fmt.Errorf("%s: %w", s, err)
+			}
+			p.value = v
+		}
+		p.property = name
+		p.hasValue = hasValue
+	}
+
+	if !strings.HasPrefix(comps, "/") {
+		return p, fmt.Errorf("%s: path must start with /", s)
+	}
+
+	for _, part := range splitPath(comps[1:]) {
+		name, quals, _ := strings.Cut(part, "[")
+		seg := pathSegment{name: name}
+		if quals != "" {
+			for _, q := range strings.Split(strings.TrimSuffix(quals, "]"), "][") {
+				k, v, _ := strings.Cut(q, "=")
+				v, err := url.PathUnescape(v)
+				if err != nil {
+					return p, fmt.Errorf("%s: %w", s, err)
+				}
+				switch k {
+				case "UID":
+					seg.uid = v
+				case "RID":
+					seg.rid = v
+				default:
+					return p, fmt.Errorf("%s: unknown qualifier %s", s, k)
+				}
+			}
+		}
+		p.segments = append(p.segments, seg)
+	}
+
+	if len(p.segments) == 0 || p.segments[0].name != "VCALENDAR" {
+		return p, fmt.Errorf("%s: path must start with /VCALENDAR", s)
+	}
+
+	return p, nil
+}
+
+// splitPath splits on "/" except within square brackets.
+func splitPath(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '/':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// find returns the components that the path's segments identify.
+func (p path) find(root *rawComponent) ([]*rawComponent, error) {
+	if !p.segments[0].matches(root) {
+		return nil, fmt.Errorf("%w: %s not found", ErrDrift, p.segments[0].name)
+	}
+
+	found := []*rawComponent{root}
+	for _, seg := range p.segments[1:] {
+		var next []*rawComponent
+		for _, c := range found {
+			for _, sub := range c.Components {
+				if seg.matches(sub) {
+					next = append(next, sub)
+				}
+			}
+		}
+
+		if len(next) == 0 {
+			return nil, fmt.Errorf("%w: %s not found", ErrDrift, p.String())
+		}
+		found = next
+	}
+
+	return found, nil
+}
+
+// findProperty returns the index of the first matching property, or -1.
+func (p path) findProperty(c *rawComponent) int {
+	for i, prop := range c.Properties {
+		if prop.Name == p.property && (!p.hasValue || prop.Value == p.value) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p path) String() string {
+	buf := &strings.Builder{}
+	for _, seg := range p.segments {
+		buf.WriteString("/" + seg.name)
+		if seg.uid != "" {
+			buf.WriteString("[UID=" + escapePath(seg.uid) + "]")
+		}
+		if seg.rid != "" {
+			buf.WriteString("[RID=" + escapePath(seg.rid) + "]")
+		}
+	}
+	if p.property != "" {
+		buf.WriteString("#" + p.property)
+		if p.hasValue {
+			buf.WriteString("[=" + escapePath(p.value) + "]")
+		}
+	}
+	return buf.String()
+}
+
+func componentPath(parent string, c *rawComponent) string {
+	uid, rid := c.identity()
+	s := parent + "/" + c.Name
+	if uid != "" {
+		s += "[UID=" + escapePath(uid) + "]"
+	}
+	if rid != "" {
+		s += "[RID=" + escapePath(rid) + "]"
+	}
+	return s
+}
+
+func propertyPath(target string, p rawProperty) string {
+	return target + "#" + p.Name + "[=" + escapePath(p.Value) + "]"
+}
+
+// pathEscaper percent-encodes the characters that have meaning in paths.
+var pathEscaper = strings.NewReplacer("%", "%25", "[", "%5B", "]", "%5D", "/", "%2F", "#", "%23")
+
+// escapePath percent-encodes a qualifier value; url.PathUnescape reverses it.
+func escapePath(s string) string {
+	return pathEscaper.Replace(s)
+}
+
+// identity returns the UID and RECURRENCE-ID of a component.
+func (c *rawComponent) identity() (uid, rid string) {
+	for _, p := range c.Properties {
+		switch p.Name {
+		case "UID":
+			uid = p.Value
+		case "RECURRENCE-ID":
+			rid = p.Value
+		}
+	}
+	return uid, rid
+}
+
+func (c *rawComponent) clone() *rawComponent {
+	cc := &rawComponent{Name: c.Name, Properties: append([]rawProperty(nil), c.Properties...)}
+	for _, sub := range c.Components {
+		cc.Components = append(cc.Components, sub.clone())
+	}
+	return cc
+}
+
+func (c *rawComponent) subComponentsString() string {
+	buf := &strings.Builder{}
+	for _, sub := range c.Components {
+		sub.writeTo(buf)
+	}
+	return buf.String()
+}
+
+func subComponentNames(cc []*rawComponent) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, c := range cc {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// rawCalendar encodes a calendar and reads it back in its raw form.
+func rawCalendar(c *VCalendar) (*rawComponent, error) {
+	return NewEncoder(nil).raw(c)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Validate checks the patch and the targets and deletions of its patches,
+// returning every problem found.
+func (vp *VPatch) Validate() Problems {
+	return vp.validate("", 0, value.TextValue{})
+}
+
+func (vp *VPatch) validate(parent string, index int, method value.TextValue) Problems {
+	v := &validation{parent: parent, name: "VPATCH", uid: vp.UID, index: index}
+	v.schema("VPATCH", newCounter().
+		add("DTSTAMP", &vp.DTStamp).
+		add("UID", &vp.UID))
+	v.values("DTSTAMP", &vp.DTStamp)
+
+	for i, p := range vp.Patches {
+		pv := &validation{parent: v.within(), name: "PATCH", index: i}
+		counts := newCounter()
+		if p.Target != "" {
+			counts.add("PATCH-TARGET", value.Raw(p.Target))
+		}
+		for _, d := range p.Delete {
+			counts.add("PATCH-DELETE", value.Raw(d))
+		}
+		pv.schema("PATCH", counts)
+
+		if p.Target != "" {
+			pv.patchPath("PATCH-TARGET", p.Target)
+		}
+		for _, d := range p.Delete {
+			pv.patchPath("PATCH-DELETE", d)
+		}
+		v.problems = append(v.problems, pv.problems...)
+	}
+
+	return v.problems
+}
+
+// patchPath checks that a property holds a valid patch path.
+func (v *validation) patchPath(property, s string) {
+	if _, err := parsePath(s); err != nil {
+		v.add(property, InvalidValue, SeverityError, "%v", err)
+	}
+}
+
+// EncodeIcal serialises the patch to the buffer in iCalendar ics format
+// (a VComponent method).
+func (vp *VPatch) EncodeIcal(b *ics.Buffer, method value.TextValue) error {
+	if err := vp.validate("", 0, method).Err(); err != nil {
+		return err
+	}
+	return vp.encodeIcal(b, method)
+}
+
+// encodeIcal writes the patch without validating it first.
+func (vp *VPatch) encodeIcal(b *ics.Buffer, method value.TextValue) error {
+	b.WriteLine("BEGIN:VPATCH")
+	b.WriteValuerLine(true, "DTSTAMP", vp.DTStamp)
+	b.WriteValuerLine(true, "UID", vp.UID)
+
+	for _, p := range vp.Patches {
+		b.WriteLine("BEGIN:PATCH")
+		b.WriteValuerLine(true, "PATCH-TARGET", value.Raw(p.Target))
+		for _, d := range p.Delete {
+			b.WriteValuerLine(true, "PATCH-DELETE", value.Raw(d))
+		}
+		for _, prop := range p.properties {
+			prop.encode(b)
+		}
+		for _, sub := range p.components {
+			sub.encode(b)
+		}
+		b.WriteLine("END:PATCH")
+	}
+
+	b.WriteLine("END:VPATCH")
+
+	return b.Flush()
+}
+
+func (p rawProperty) encode(b *ics.Buffer) {
+	b.WriteValuerLine(true, p.Name, value.ParseRaw(p.Parameters, p.Value))
+}
+
+func (c *rawComponent) encode(b *ics.Buffer) {
+	b.WriteLine("BEGIN:" + c.Name)
+	for _, p := range c.Properties {
+		p.encode(b)
+	}
+	for _, sub := range c.Components {
+		sub.encode(b)
+	}
+	b.WriteLine("END:" + c.Name)
+}
+
+func decodeVPatch(raw *rawComponent) (*VPatch, error) {
+	vp := &VPatch{}
+
+	for _, p := range raw.Properties {
+		var err error
+		switch p.Name {
+		case "UID":
+			vp.UID = value.ParseText(p.Parameters, p.Value)
+		case "DTSTAMP":
+			vp.DTStamp, err = value.ParseDateTime(p.Parameters, p.Value)
+		}
+
+		if err != nil {
+			return nil, p.wrap(err)
+		}
+	}
+
+	for _, sub := range raw.Components {
+		if sub.Name != "PATCH" {
+			continue // not supported
+		}
+
+		var patch Patch
+		for _, p := range sub.Properties {
+			switch p.Name {
+			case "PATCH-TARGET":
+				patch.Target = p.Value
+			case "PATCH-DELETE":
+				patch.Delete = append(patch.Delete, p.Value)
+			default:
+				patch.properties = append(patch.properties, p)
+			}
+		}
+
+		patch.components = sub.Components
+		vp.Patches = append(vp.Patches, patch)
+	}
+
+	return vp, nil
+}
@@ -0,0 +1,228 @@
+package ical2_test
+
+import (
+	"errors"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/value"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVPatchRoundTrip(t *testing.T) {
+	old, err := ical2.Decode(strings.NewReader(diffOld))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	new, err := ical2.Decode(strings.NewReader(strings.Replace(diffNew, "VERSION:2.0\n", "VERSION:2.0\nNAME:Renamed\n", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vp, err := ical2.NewVPatch("patch-1", old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the patch survives being sent
+	vp.DTStamp = value.TStamp(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	doc := ical2.NewVCalendar("-//Test//EN").With(vp).String()
+	received, err := ical2.Decode(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, doc)
+	}
+	if len(received.VComponent) != 1 {
+		t.Fatalf("got %d components\n%s", len(received.VComponent), doc)
+	}
+
+	for _, exp := range []string{
+		"PATCH-DELETE:/VCALENDAR/VEVENT[UID=a]#SUMMARY[=Planning]\n",
+		"PATCH-DELETE:/VCALENDAR/VEVENT[UID=b][RID=20240110T100000Z]\n",
+	} {
+		if !strings.Contains(doc, exp) {
+			t.Errorf("missing %q in\n%s", exp, doc)
+		}
+	}
+
+	patched, err := received.VComponent[0].(*ical2.VPatch).Apply(old)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, doc)
+	}
+
+	if patched.String() != new.String() {
+		t.Errorf("expected\n%s\nbut got\n%s", new, patched)
+	}
+}
+
+func TestVPatchDrift(t *testing.T) {
+	old, _ := ical2.Decode(strings.NewReader(diffOld))
+	new, _ := ical2.Decode(strings.NewReader(diffNew))
+
+	vp, err := ical2.NewVPatch("patch-1", old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []string{
+		strings.Replace(diffOld, "SUMMARY:Planning", "SUMMARY:Edited elsewhere", 1),
+		strings.Replace(diffOld, "mailto:x@y", "mailto:z@y", 1),
+		strings.Replace(diffOld, "UID:b", "UID:e", 1),
+		strings.Replace(diffOld, "END:VCALENDAR", "BEGIN:VEVENT\nDTSTART:20240105T100000Z\nDTSTAMP:20240201T000000Z\nUID:d\nEND:VEVENT\nEND:VCALENDAR", 1),
+	}
+
+	for i, c := range cases {
+		drifted, err := ical2.Decode(strings.NewReader(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = vp.Apply(drifted)
+		if !errors.Is(err, ical2.ErrDrift) {
+			t.Errorf("%d: got %v", i, err)
+		}
+	}
+}
+
+func TestVPatchParameterInPlace(t *testing.T) {
+	old, _ := ical2.Decode(strings.NewReader(diffOld))
+	new, _ := ical2.Decode(strings.NewReader(strings.Replace(diffOld, "PARTSTAT=NEEDS-ACTION", "PARTSTAT=ACCEPTED", 1)))
+
+	vp, err := ical2.NewVPatch("patch-1", old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vp.Patches) != 1 || vp.Patches[0].Target != "/VCALENDAR/VEVENT[UID=a]#ATTENDEE[=mailto:x@y]" {
+		t.Fatalf("got %+v", vp.Patches)
+	}
+
+	patched, err := vp.Apply(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if patched.String() != new.String() {
+		t.Errorf("expected\n%s\nbut got\n%s", new, patched)
+	}
+}
+
+func TestVPatchNoChanges(t *testing.T) {
+	old, _ := ical2.Decode(strings.NewReader(diffOld))
+
+	vp, err := ical2.NewVPatch("patch-1", old, old)
+	if err != nil || len(vp.Patches) != 0 {
+		t.Errorf("got %v %v", vp.Patches, err)
+	}
+}
+
+func TestVPatchEscapesPaths(t *testing.T) {
+	dt := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	event := func(uid, summary string) *ical2.VEvent {
+		return &ical2.VEvent{UID: value.Text(uid), DTStamp: value.TStamp(dt), Start: value.TStamp(dt),
+			Summary: value.Text(summary), Comment: value.Texts("see [1]", "a/b#c")}
+	}
+
+	uids := []string{"a#1", "b]x", "c[UID=d]", "e/f", "g%2F"}
+	old := ical2.NewVCalendar("-//Test//EN")
+	new := ical2.NewVCalendar("-//Test//EN")
+	for _, uid := range uids {
+		old.With(event(uid, "before"))
+		new.With(event(uid, "after"))
+	}
+	new.VComponent[0].(*ical2.VEvent).Comment = value.Texts("see [2]")
+
+	vp, err := ical2.NewVPatch("patch-1", old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := ical2.NewVCalendar("-//Test//EN").With(vp).String()
+	for _, exp := range []string{
+		"PATCH-TARGET:/VCALENDAR/VEVENT[UID=a%231]\n",
+		"PATCH-TARGET:/VCALENDAR/VEVENT[UID=c%5BUID=d%5D]\n",
+		"PATCH-DELETE:/VCALENDAR/VEVENT[UID=a%231]#COMMENT[=see %5B1%5D]\n",
+	} {
+		if !strings.Contains(doc, exp) {
+			t.Errorf("missing %q in\n%s", exp, doc)
+		}
+	}
+
+	received, err := ical2.Decode(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patched, err := received.VComponent[0].(*ical2.VPatch).Apply(old)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, doc)
+	}
+	if d, _ := ical2.Differences(patched, new); len(d) != 0 {
+		t.Errorf("got %v", d)
+	}
+}
+
+func TestVPatchKeepsUnmodelledContent(t *testing.T) {
+	dt := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	old := ical2.NewVCalendar("-//Test//EN").
+		With(&ical2.VFreeBusy{UID: value.Text("fb"), DTStamp: value.TStamp(dt), Start: value.TStamp(dt)})
+
+	// free/busy components cannot hold nested components, so this can only be generic
+	fb := ical2.NewComponent("VFREEBUSY").
+		Add("UID", value.Text("fb")).
+		Add("DTSTAMP", value.TStamp(dt)).
+		Add("DTSTART", value.TStamp(dt)).
+		With(ical2.NewComponent("X-NOTE").Add("SUMMARY", value.Text("kept")))
+	new := ical2.NewVCalendar("-//Test//EN").With(fb)
+
+	vp, err := ical2.NewVPatch("patch-1", old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patched, err := vp.Apply(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := ical2.Differences(patched, new); len(d) != 0 {
+		t.Errorf("got %v\n%s", d, patched)
+	}
+	if _, ok := patched.VComponent[0].(*ical2.Component); !ok {
+		t.Errorf("got %T", patched.VComponent[0])
+	}
+}
+
+func TestVPatchValidate(t *testing.T) {
+	vp := &ical2.VPatch{
+		UID: value.Text("p1"),
+		Patches: []ical2.Patch{
+			{Delete: []string{"/VCALENDAR/VEVENT[UID=1]"}},
+			{Target: "/VEVENT[UID=1]"},
+		},
+	}
+	c := ical2.NewVCalendar("-//Test//EN").With(vp)
+
+	expected := []struct {
+		path string
+		code ical2.ErrorCode
+	}{
+		{"VPATCH[uid=p1]/DTSTAMP", ical2.MissingProperty},
+		{"VPATCH[uid=p1]/PATCH[0]/PATCH-TARGET", ical2.MissingProperty},
+		{"VPATCH[uid=p1]/PATCH[1]/PATCH-TARGET", ical2.InvalidValue},
+	}
+
+	problems := c.Validate()
+	if len(problems) != len(expected) {
+		t.Fatalf("got %v", problems)
+	}
+	for i, exp := range expected {
+		if p := problems[i]; p.Path != exp.path || p.Code != exp.code || p.Severity != ical2.SeverityError {
+			t.Errorf("%d: got %+v", i, p)
+		}
+	}
+
+	buf := &strings.Builder{}
+	if err := c.EncodePlain(buf); err == nil || buf.Len() > 0 {
+		t.Errorf("got %v\n%s", err, buf)
+	}
+}
@@ -0,0 +1,168 @@
+// Package webcal supports calendar subscriptions, i.e. read-only calendars that are
+// published over HTTP (often using webcal:// URLs) and polled by their subscribers.
+package webcal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/ics"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of iCalendar data.
+const ContentType = "text/calendar; charset=utf-8"
+
+// Handler serves a calendar as a subscription feed. It sets the ETag and Last-Modified
+// headers so that conditional requests are answered with 304 Not Modified, and a
+// Cache-Control header based on the calendar's RefreshInterval. The content is
+// compressed with gzip when the client accepts it.
+type Handler struct {
+	// Calendar provides the calendar to serve; it is called for every request. If it
+	// returns nil, the response is 404 Not Found.
+	Calendar func(r *http.Request) (*ical2.VCalendar, error)
+
+	// Filename, if not blank, is sent in a Content-Disposition header.
+	Filename string
+
+	// DefaultMaxAge is used for the Cache-Control header when the calendar has no
+	// RefreshInterval. If this is also zero, no Cache-Control header is sent.
+	DefaultMaxAge time.Duration
+}
+
+// NewHandler returns a handler that serves a fixed calendar. The calendar is
+// encoded afresh for each request, so it must not be modified concurrently.
+func NewHandler(cal *ical2.VCalendar) *Handler {
+	return HandlerFunc(func(*http.Request) (*ical2.VCalendar, error) {
+		return cal, nil
+	})
+}
+
+// HandlerFunc returns a handler that serves the calendars produced by a function.
+func HandlerFunc(fn func(r *http.Request) (*ical2.VCalendar, error)) *Handler {
+	return &Handler{Calendar: fn}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	cal, err := h.Calendar(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if cal == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := cal.Encode(buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", ContentType)
+	header.Add("Vary", "Accept-Encoding")
+
+	if h.Filename != "" {
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": h.Filename}))
+	}
+
+	if maxAge := h.maxAge(cal); maxAge > 0 {
+		header.Set("Cache-Control", "max-age="+strconv.Itoa(int(maxAge/time.Second)))
+	}
+
+	etag := ETag(buf.Bytes())
+
+	var lastModified time.Time
+	if ics.IsDefined(cal.LastModified) {
+		lastModified = cal.LastModified.Value
+	}
+
+	content := buf.Bytes()
+	if acceptsGzip(r) {
+		// the compressed representation needs its own strong validator,
+		// but a client holding either one has current content
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			header.Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		etag = strings.TrimSuffix(etag, `"`) + `-gzip"`
+		content = compress(content)
+		header.Set("Content-Encoding", "gzip")
+	}
+
+	header.Set("ETag", etag)
+
+	// ServeContent handles If-None-Match, If-Modified-Since, HEAD and ranges
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(content))
+}
+
+// maxAge chooses the Cache-Control max-age.
+func (h *Handler) maxAge(cal *ical2.VCalendar) time.Duration {
+	if ics.IsDefined(cal.RefreshInterval) {
+		if d, err := cal.RefreshInterval.Duration(); err == nil && d > 0 {
+			return d
+		}
+	}
+	return h.DefaultMaxAge
+}
+
+// ETag computes a strong entity tag from the content of a calendar.
+func ETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip tests the Accept-Encoding header for gzip with a non-zero quality.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, q, _ := strings.Cut(enc, ";")
+		if strings.TrimSpace(strings.ToLower(name)) != "gzip" {
+			continue
+		}
+		q = strings.TrimSpace(q)
+		if v, ok := strings.CutPrefix(q, "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && f == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func compress(content []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	zw.Write(content)
+	zw.Close()
+	return buf.Bytes()
+}
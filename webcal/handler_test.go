@@ -0,0 +1,150 @@
+package webcal
+
+import (
+	"compress/gzip"
+	"errors"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/value"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCalendar() *ical2.VCalendar {
+	dt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	c := ical2.NewVCalendar("-//Test//EN").With(&ical2.VEvent{
+		UID:     value.Text("123"),
+		DTStamp: value.TStamp(dt),
+		Start:   value.DateTime(dt.Add(time.Hour)),
+		Summary: value.Text("Meeting"),
+	})
+	c.LastModified = value.TStamp(dt)
+	c.RefreshInterval = value.Duration("PT12H")
+	return c
+}
+
+func TestHandlerHeaders(t *testing.T) {
+	h := NewHandler(testCalendar())
+	h.Filename = "team.ics"
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/team.ics", nil))
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %d", resp.StatusCode)
+	}
+
+	expect := map[string]string{
+		"Content-Type":        ContentType,
+		"Cache-Control":       "max-age=43200",
+		"Last-Modified":       "Mon, 01 Jan 2024 09:00:00 GMT",
+		"Content-Disposition": "attachment; filename=team.ics",
+	}
+	for k, v := range expect {
+		if got := resp.Header.Get(k); got != v {
+			t.Errorf("%s: expected %q but got %q", k, v, got)
+		}
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(string(body), "BEGIN:VCALENDAR\r\n") {
+		t.Errorf("got %s", body)
+	}
+	if resp.Header.Get("ETag") != ETag(body) {
+		t.Errorf("got %s", resp.Header.Get("ETag"))
+	}
+}
+
+func TestHandlerConditional(t *testing.T) {
+	h := NewHandler(testCalendar())
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := w.Result().Header.Get("ETag")
+
+	cases := []struct {
+		header, value string
+		status        int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", `"other", ` + etag, http.StatusNotModified},
+		{"If-None-Match", `"other"`, http.StatusOK},
+		{"If-Modified-Since", "Mon, 01 Jan 2024 09:00:00 GMT", http.StatusNotModified},
+		{"If-Modified-Since", "Mon, 01 Jan 2024 08:59:59 GMT", http.StatusOK},
+	}
+
+	for i, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(c.header, c.value)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%d: expected %d but got %d", i, c.status, w.Code)
+		}
+	}
+}
+
+func TestHandlerGzip(t *testing.T) {
+	h := NewHandler(testCalendar())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	resp := w.Result()
+	if resp.Header.Get("Content-Encoding") != "gzip" || !strings.HasSuffix(resp.Header.Get("ETag"), `-gzip"`) {
+		t.Fatalf("got %v", resp.Header)
+	}
+
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(zr)
+	if !strings.Contains(string(body), "SUMMARY:Meeting\r\n") {
+		t.Errorf("got %s", body)
+	}
+
+	r.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("got %d", w.Code)
+	}
+
+	r.Header.Set("Accept-Encoding", "gzip;q=0")
+	r.Header.Del("If-None-Match")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Result().Header.Get("Content-Encoding") != "" {
+		t.Errorf("got %v", w.Result().Header)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	cases := []struct {
+		method string
+		fn     func(*http.Request) (*ical2.VCalendar, error)
+		status int
+	}{
+		{http.MethodPost, func(*http.Request) (*ical2.VCalendar, error) { return testCalendar(), nil }, http.StatusMethodNotAllowed},
+		{http.MethodGet, func(*http.Request) (*ical2.VCalendar, error) { return nil, nil }, http.StatusNotFound},
+		{http.MethodGet, func(*http.Request) (*ical2.VCalendar, error) { return nil, errors.New("boom") }, http.StatusInternalServerError},
+		{http.MethodGet, func(*http.Request) (*ical2.VCalendar, error) {
+			return ical2.NewVCalendar("x").With(&ical2.VEvent{}), nil
+		}, http.StatusInternalServerError},
+	}
+
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		HandlerFunc(c.fn).ServeHTTP(w, httptest.NewRequest(c.method, "/", nil))
+		if w.Code != c.status {
+			t.Errorf("%d: expected %d but got %d", i, c.status, w.Code)
+		}
+	}
+}
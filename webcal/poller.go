@@ -0,0 +1,454 @@
+package webcal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/rickb777/ical2"
+	"github.com/rickb777/ical2/ics"
+	"github.com/rickb777/ical2/value"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock provides the time. It allows the Poller to be tested without waiting.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the real clock.
+var SystemClock Clock = systemClock{}
+
+//-------------------------------------------------------------------------------------------------
+
+// ChangeKind describes how a component in a feed changed between polls.
+type ChangeKind int
+
+const (
+	// Added means the component is new.
+	Added ChangeKind = iota + 1
+	// Changed means the component has a different SEQUENCE or different content.
+	Changed
+	// Removed means the component is no longer in the feed.
+	Removed
+)
+
+// String returns "added", "changed" or "removed".
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Changed:
+		return "changed"
+	case Removed:
+		return "removed"
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Change describes a component that was added to, changed in or removed from a feed.
+type Change struct {
+	// Feed is the URL of the feed, as it was passed to Poller.Add.
+	Feed string
+	Kind ChangeKind
+	// UID identifies the component.
+	UID string
+	// RecurrenceId identifies the recurrence instance, if any.
+	RecurrenceId time.Time
+	// Sequence is the SEQUENCE of the component.
+	Sequence int
+	// Component is the new component, or the old one when it was removed.
+	Component ical2.VComponent
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Poller fetches remote calendar feeds periodically and reports the changes in them.
+//
+// Each feed is polled at the interval given by its REFRESH-INTERVAL property (RFC 7986),
+// or failing that its X-PUBLISHED-TTL property, or failing that DefaultInterval.
+// Conditional requests are used so that unchanged feeds cost little. When a feed
+// specifies a different SOURCE, that location is used for subsequent polls. After
+// errors, polling backs off exponentially, up to MaxBackoff.
+type Poller struct {
+	// Client makes the HTTP requests.
+	Client *http.Client
+	// Clock provides the time.
+	Clock Clock
+	// DefaultInterval is used when a feed does not specify its refresh interval.
+	DefaultInterval time.Duration
+	// MinInterval is the shortest interval allowed, whatever the feed specifies.
+	MinInterval time.Duration
+	// MaxBackoff limits the retry interval after repeated errors.
+	MaxBackoff time.Duration
+	// OnChange is called for every change found. The calls for a feed are made one
+	// poll at a time, in order, so OnChange must not poll the same feed.
+	OnChange func(Change)
+	// OnError, if not nil, is called for every failed poll.
+	OnError func(feed string, err error)
+
+	mu    sync.Mutex
+	feeds map[string]*feed
+}
+
+type feed struct {
+	// poll is held while the feed is polled, so that polls of a feed do not overlap
+	poll sync.Mutex
+
+	// these are guarded by Poller.mu
+	validators
+	interval time.Duration
+	next     time.Time
+	failures int
+
+	// items is guarded by poll
+	items map[itemKey]item
+}
+
+// validators are used to make conditional requests.
+type validators struct {
+	url          string // where the feed is currently fetched from
+	etag         string
+	lastModified string
+}
+
+type itemKey struct {
+	uid          string
+	recurrenceId int64 // UnixNano or zero
+}
+
+type item struct {
+	sequence  int
+	hash      [sha256.Size]byte
+	component ical2.VComponent
+}
+
+// NewPoller returns a poller that calls onChange for every change found. The
+// defaults are to use http.DefaultClient, the system clock, a one-hour default
+// interval, a one-minute minimum interval and a one-day maximum backoff.
+func NewPoller(onChange func(Change)) *Poller {
+	return &Poller{
+		Client:          http.DefaultClient,
+		Clock:           SystemClock,
+		DefaultInterval: time.Hour,
+		MinInterval:     time.Minute,
+		MaxBackoff:      24 * time.Hour,
+		OnChange:        onChange,
+		feeds:           make(map[string]*feed),
+	}
+}
+
+// Add registers a feed URL. It will be polled at the next opportunity. The
+// webcal and webcals schemes are treated as https.
+func (p *Poller) Add(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.feeds[url]; !exists {
+		p.feeds[url] = &feed{validators: validators{url: httpURL(url)}, next: p.Clock.Now(), items: make(map[itemKey]item)}
+	}
+}
+
+// Remove unregisters a feed URL.
+func (p *Poller) Remove(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.feeds, url)
+}
+
+// Run polls the feeds whenever they are due, until the context is cancelled.
+func (p *Poller) Run(ctx context.Context) error {
+	for {
+		next := p.PollDue(ctx)
+
+		wait := p.DefaultInterval
+		if !next.IsZero() {
+			wait = next.Sub(p.Clock.Now())
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.Clock.After(wait):
+		}
+	}
+}
+
+// PollDue polls every feed that is due now. It returns the time when the next feed
+// will be due, which is zero if there are no feeds.
+func (p *Poller) PollDue(ctx context.Context) time.Time {
+	p.mu.Lock()
+	now := p.Clock.Now()
+	var due []string
+	for url, f := range p.feeds {
+		if !f.next.After(now) {
+			due = append(due, url)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, url := range due {
+		p.Poll(ctx, url)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var next time.Time
+	for _, f := range p.feeds {
+		if next.IsZero() || f.next.Before(next) {
+			next = f.next
+		}
+	}
+	return next
+}
+
+// Poll fetches one feed immediately, reports any changes and schedules its next poll.
+// It is safe to call concurrently with Run and PollDue; polls of the same feed wait
+// for each other.
+func (p *Poller) Poll(ctx context.Context, url string) error {
+	p.mu.Lock()
+	f, exists := p.feeds[url]
+	p.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("%s: unknown feed", url)
+	}
+
+	f.poll.Lock()
+	defer f.poll.Unlock()
+
+	changes, err := p.fetch(ctx, url, f)
+
+	p.mu.Lock()
+	now := p.Clock.Now()
+	if err != nil {
+		f.failures++
+		f.next = now.Add(p.backoff(f))
+	} else {
+		f.failures = 0
+		f.next = now.Add(p.interval(f))
+	}
+	p.mu.Unlock()
+
+	if err != nil {
+		if p.OnError != nil {
+			p.OnError(url, err)
+		}
+		return err
+	}
+
+	for _, c := range changes {
+		p.OnChange(c)
+	}
+	return nil
+}
+
+// fetch gets the feed and updates its items. The feed's poll lock must be held.
+func (p *Poller) fetch(ctx context.Context, url string, f *feed) ([]Change, error) {
+	p.mu.Lock()
+	v := f.validators
+	p.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "text/calendar")
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("GET %s: %s", v.url, resp.Status)
+	}
+
+	cal, err := ical2.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", v.url, err)
+	}
+
+	v.etag = resp.Header.Get("ETag")
+	v.lastModified = resp.Header.Get("Last-Modified")
+
+	if ics.IsDefined(cal.Source) {
+		if src := httpURL(cal.Source.Value); src != v.url {
+			// the feed has moved; the validators belong to the old location
+			v = validators{url: src}
+		}
+	}
+
+	p.mu.Lock()
+	f.validators = v
+	f.interval = feedInterval(cal)
+	p.mu.Unlock()
+
+	return f.update(url, cal), nil
+}
+
+// update replaces the feed's items with those in the calendar, returning the differences.
+// The feed's poll lock must be held.
+func (f *feed) update(url string, cal *ical2.VCalendar) []Change {
+	var changes []Change
+	items := make(map[itemKey]item)
+
+	for _, c := range cal.VComponent {
+		key, seq, ok := identify(c)
+		if !ok {
+			continue
+		}
+
+		it := item{sequence: seq, hash: hashComponent(c, cal.Method), component: c}
+		items[key] = it
+
+		change := Change{Feed: url, UID: key.uid, Sequence: seq, Component: c}
+		if key.recurrenceId != 0 {
+			change.RecurrenceId = time.Unix(0, key.recurrenceId).UTC()
+		}
+
+		old, existed := f.items[key]
+		switch {
+		case !existed:
+			change.Kind = Added
+		case old.sequence != seq || old.hash != it.hash:
+			change.Kind = Changed
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	for key, old := range f.items {
+		if _, exists := items[key]; !exists {
+			change := Change{Feed: url, Kind: Removed, UID: key.uid, Sequence: old.sequence, Component: old.component}
+			if key.recurrenceId != 0 {
+				change.RecurrenceId = time.Unix(0, key.recurrenceId).UTC()
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	f.items = items
+	return changes
+}
+
+func (p *Poller) interval(f *feed) time.Duration {
+	d := f.interval
+	if d <= 0 {
+		d = p.DefaultInterval
+	}
+	if d < p.MinInterval {
+		d = p.MinInterval
+	}
+	return d
+}
+
+func (p *Poller) backoff(f *feed) time.Duration {
+	d := p.interval(f)
+	if f.interval <= 0 {
+		// nothing is known about the feed yet, so start retrying quickly
+		d = p.MinInterval
+	}
+
+	for i := 1; i < f.failures && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// feedInterval finds the refresh interval from REFRESH-INTERVAL or X-PUBLISHED-TTL.
+// It returns zero if neither is present.
+func feedInterval(cal *ical2.VCalendar) time.Duration {
+	if ics.IsDefined(cal.RefreshInterval) {
+		if d, err := cal.RefreshInterval.Duration(); err == nil && d > 0 {
+			return d
+		}
+	}
+
+	for _, ext := range cal.Extensions {
+		if !strings.EqualFold(ext.Key, "X-PUBLISHED-TTL") {
+			continue
+		}
+
+		var s string
+		switch v := ext.Value.(type) {
+		case value.RawValue:
+			s = v.Value
+		case value.TextValue:
+			s = v.Value
+		case value.DurationValue:
+			s = v.Value
+		}
+
+		if dv, err := value.ParseDuration(nil, s); err == nil {
+			if d, err := dv.Duration(); err == nil && d > 0 {
+				return d
+			}
+		}
+	}
+
+	return 0
+}
+
+// identify finds the UID, recurrence ID and sequence of the components that have them.
+func identify(c ical2.VComponent) (itemKey, int, bool) {
+	switch v := c.(type) {
+	case *ical2.VEvent:
+		key := itemKey{uid: v.UID.Value}
+		if ics.IsDefined(v.RecurrenceId) {
+			key.recurrenceId = v.RecurrenceId.Value.UnixNano()
+		}
+		return key, v.Sequence.Value, true
+	case *ical2.VFreeBusy:
+		return itemKey{uid: v.UID.Value}, 0, true
+	}
+	return itemKey{}, 0, false
+}
+
+func hashComponent(c ical2.VComponent, method value.TextValue) [sha256.Size]byte {
+	buf := &bytes.Buffer{}
+	b := ics.NewBuffer(buf, "\n")
+	c.EncodeIcal(b, method)
+	b.Flush()
+	return sha256.Sum256(buf.Bytes())
+}
+
+func httpURL(url string) string {
+	lower := strings.ToLower(url)
+	for _, scheme := range []string{"webcals://", "webcal://"} {
+		if strings.HasPrefix(lower, scheme) {
+			return "https://" + url[len(scheme):]
+		}
+	}
+	return url
+}
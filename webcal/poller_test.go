@@ -0,0 +1,271 @@
+package webcal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// feedServer serves whatever feed content it currently holds, honouring If-None-Match.
+type feedServer struct {
+	mu       sync.Mutex
+	content  string
+	status   int
+	requests int
+	notMod   int
+}
+
+func (s *feedServer) set(content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content = content
+}
+
+func (s *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, len(s.content)+strings.Count(s.content, "SEQUENCE:2"))
+	if r.Header.Get("If-None-Match") == etag {
+		s.notMod++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", ContentType)
+	fmt.Fprint(w, s.content)
+}
+
+func feedContent(props string, events ...string) string {
+	return "BEGIN:VCALENDAR\nPRODID:-//Test//EN\nVERSION:2.0\n" + props + strings.Join(events, "") + "END:VCALENDAR\n"
+}
+
+func feedEvent(uid string, seq int, summary string) string {
+	return fmt.Sprintf("BEGIN:VEVENT\nDTSTAMP:20240101T000000Z\nUID:%s\nDTSTART:20240102T100000Z\nSUMMARY:%s\nSEQUENCE:%d\nEND:VEVENT\n", uid, summary, seq)
+}
+
+type recorder struct {
+	changes []string
+}
+
+func (r *recorder) record(c Change) {
+	r.changes = append(r.changes, fmt.Sprintf("%s %s %d", c.Kind, c.UID, c.Sequence))
+}
+
+func (r *recorder) take() string {
+	sort.Strings(r.changes)
+	s := strings.Join(r.changes, "; ")
+	r.changes = nil
+	return s
+}
+
+func TestPollerChanges(t *testing.T) {
+	fs := &feedServer{content: feedContent("REFRESH-INTERVAL;VALUE=DURATION:PT2H\n", feedEvent("a", 0, "A"), feedEvent("b", 0, "B"))}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rec := &recorder{}
+	p := NewPoller(rec.record)
+	p.Client = srv.Client()
+	p.Clock = clock
+	p.Add(srv.URL)
+
+	ctx := context.Background()
+	next := p.PollDue(ctx)
+	if s := rec.take(); s != "added a 0; added b 0" {
+		t.Errorf("got %s", s)
+	}
+	if !next.Equal(clock.now.Add(2 * time.Hour)) {
+		t.Errorf("got %v", next)
+	}
+
+	// not yet due
+	clock.now = clock.now.Add(time.Hour)
+	p.PollDue(ctx)
+	if fs.requests != 1 {
+		t.Errorf("got %d requests", fs.requests)
+	}
+
+	// due, but not modified
+	clock.now = clock.now.Add(time.Hour)
+	p.PollDue(ctx)
+	if fs.notMod != 1 || rec.take() != "" {
+		t.Errorf("got %d not-modified responses", fs.notMod)
+	}
+
+	fs.set(feedContent("REFRESH-INTERVAL;VALUE=DURATION:PT2H\n", feedEvent("a", 2, "A"), feedEvent("c", 0, "C")))
+	clock.now = clock.now.Add(2 * time.Hour)
+	p.PollDue(ctx)
+	if s := rec.take(); s != "added c 0; changed a 2; removed b 0" {
+		t.Errorf("got %s", s)
+	}
+}
+
+func TestPollerPublishedTTLAndSource(t *testing.T) {
+	moved := &feedServer{content: feedContent("X-PUBLISHED-TTL:PT30M\n", feedEvent("a", 0, "A"))}
+	srv2 := httptest.NewServer(moved)
+	defer srv2.Close()
+
+	orig := &feedServer{content: feedContent("SOURCE;VALUE=URI:"+srv2.URL+"\nX-PUBLISHED-TTL:PT30M\n", feedEvent("a", 0, "A"))}
+	srv1 := httptest.NewServer(orig)
+	defer srv1.Close()
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rec := &recorder{}
+	p := NewPoller(rec.record)
+	p.Clock = clock
+	p.Add(srv1.URL)
+
+	next := p.PollDue(context.Background())
+	if !next.Equal(clock.now.Add(30 * time.Minute)) {
+		t.Errorf("got %v", next)
+	}
+
+	clock.now = next
+	p.PollDue(context.Background())
+	if orig.requests != 1 || moved.requests != 1 {
+		t.Errorf("got %d and %d requests", orig.requests, moved.requests)
+	}
+	if s := rec.take(); s != "added a 0" {
+		t.Errorf("got %s", s)
+	}
+}
+
+func TestPollerBackoff(t *testing.T) {
+	fs := &feedServer{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p := NewPoller(func(Change) {})
+	p.Clock = clock
+	p.MaxBackoff = 3 * time.Minute
+	var errs []error
+	p.OnError = func(_ string, err error) { errs = append(errs, err) }
+	p.Add(srv.URL)
+
+	var waits []time.Duration
+	for i := 0; i < 4; i++ {
+		next := p.PollDue(context.Background())
+		waits = append(waits, next.Sub(clock.now))
+		clock.now = next
+	}
+
+	exp := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	if fmt.Sprint(waits) != fmt.Sprint(exp) {
+		t.Errorf("expected %v but got %v", exp, waits)
+	}
+	if len(errs) != 4 {
+		t.Errorf("got %v", errs)
+	}
+}
+
+func TestPollerRun(t *testing.T) {
+	fs := &feedServer{content: feedContent("", feedEvent("a", 0, "A"))}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := NewPoller(func(Change) {})
+	p.Clock = clock
+	p.OnError = func(_ string, err error) {
+		if ctx.Err() == nil {
+			t.Error(err)
+		}
+	}
+	p.Add(srv.URL)
+
+	polls := 0
+	go func() {
+		for polls < 3 {
+			time.Sleep(time.Millisecond)
+			fs.mu.Lock()
+			polls = fs.requests
+			fs.mu.Unlock()
+		}
+		cancel()
+	}()
+
+	if err := p.Run(ctx); err != context.Canceled {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestHttpURL(t *testing.T) {
+	cases := map[string]string{
+		"webcal://example.com/a.ics":  "https://example.com/a.ics",
+		"WEBCALS://example.com/a.ics": "https://example.com/a.ics",
+		"http://example.com/a.ics":    "http://example.com/a.ics",
+	}
+
+	for in, exp := range cases {
+		if s := httpURL(in); s != exp {
+			t.Errorf("%s: expected %s but got %s", in, exp, s)
+		}
+	}
+}
+
+func TestPollerConcurrentPolls(t *testing.T) {
+	fs := &feedServer{content: feedContent("", feedEvent("a", 0, "A"), feedEvent("b", 0, "B"))}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	var mu sync.Mutex
+	rec := &recorder{}
+	p := NewPoller(func(c Change) {
+		mu.Lock()
+		defer mu.Unlock()
+		rec.record(c)
+	})
+	p.Client = srv.Client()
+	p.Add(srv.URL)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			p.Poll(ctx, srv.URL)
+		}()
+		go func() {
+			defer wg.Done()
+			p.PollDue(ctx)
+		}()
+	}
+	wg.Wait()
+
+	// each change is reported once, however the polls interleave
+	if s := rec.take(); s != "added a 0; added b 0" {
+		t.Errorf("got %s", s)
+	}
+}